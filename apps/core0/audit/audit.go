@@ -0,0 +1,291 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/zero-os/0-core/base/pm"
+)
+
+const (
+	cmdAuditQuery = "audit.query"
+
+	DefaultAuditFile  = "/var/log/core-audit.log"
+	MaxAuditFileSize  = 10 * 1024 * 1024
+	DefaultQueryLimit = 100
+
+	MaxArgumentLength = 64
+	MaxArgumentDepth  = 2
+	Redacted          = "***"
+)
+
+var (
+	log = logging.MustGetLogger("audit")
+
+	//arguments that are never written to the audit log
+	sensitive = regexp.MustCompile(`(?i)pass|secret|token|key|jwt|credential|identity`)
+)
+
+//Record is a single audit log entry, the submission record of a command has no state, its
+//result record has the job state
+type Record struct {
+	Time      int64       `json:"time"`
+	ID        string      `json:"id"`
+	Identity  string      `json:"identity"`
+	Command   string      `json:"command"`
+	Arguments interface{} `json:"arguments,omitempty"`
+	State     pm.JobState `json:"state,omitempty"`
+}
+
+/*
+Auditor is a pre and result handler that writes an append-only log of all the commands submitted
+by a caller (commands with an identity), internal commands are not audited. A record is written
+when the command is submitted, so commands that never return (like core.reboot) are still audited,
+and another one with the job state when it exits. Once the log file reaches MaxAuditFileSize it's
+rotated, only one rotated file is kept.
+*/
+type Auditor struct {
+	file string
+	f    *os.File
+	size int64
+	m    sync.Mutex
+}
+
+func New(file string) (*Auditor, error) {
+	if file == "" {
+		file = DefaultAuditFile
+	}
+
+	if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return nil, err
+	}
+
+	a := &Auditor{file: file}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+
+	pm.RegisterBuiltIn(cmdAuditQuery, a.query)
+
+	return a, nil
+}
+
+func (a *Auditor) open() error {
+	f, err := os.OpenFile(a.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	a.f = f
+	a.size = info.Size()
+	return nil
+}
+
+func (a *Auditor) rotate() error {
+	a.f.Close()
+	if err := os.Rename(a.file, a.file+".1"); err != nil {
+		log.Errorf("failed to rotate audit log: %s", err)
+	}
+
+	return a.open()
+}
+
+//Pre handler implementation
+func (a *Auditor) Pre(cmd *pm.Command) {
+	if cmd.Identity == "" {
+		return
+	}
+
+	record := Record{
+		Time:     time.Now().Unix(),
+		ID:       cmd.ID,
+		Identity: cmd.Identity,
+		Command:  cmd.Command,
+	}
+
+	if cmd.Arguments != nil {
+		var args interface{}
+		if err := json.Unmarshal(*cmd.Arguments, &args); err == nil {
+			record.Arguments = summarize(args, 0)
+		}
+	}
+
+	if err := a.write(&record); err != nil {
+		log.Errorf("failed to write audit record for %s: %s", cmd, err)
+	}
+}
+
+//Result handler implementation
+func (a *Auditor) Result(cmd *pm.Command, result *pm.JobResult) {
+	if cmd.Identity == "" {
+		return
+	}
+
+	record := Record{
+		Time:     time.Now().Unix(),
+		ID:       cmd.ID,
+		Identity: cmd.Identity,
+		Command:  cmd.Command,
+		State:    result.State,
+	}
+
+	if err := a.write(&record); err != nil {
+		log.Errorf("failed to write audit record for %s: %s", cmd, err)
+	}
+}
+
+func (a *Auditor) write(record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	data = append(data, '\n')
+
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	if a.size+int64(len(data)) > MaxAuditFileSize {
+		if err := a.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := a.f.Write(data)
+	a.size += int64(n)
+	return err
+}
+
+//summarize builds a short version of the command arguments, sensitive values are
+//redacted, long values are truncated and deeply nested values are only counted.
+func summarize(value interface{}, depth int) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if depth >= MaxArgumentDepth {
+			return fmt.Sprintf("{%d keys}", len(value))
+		}
+
+		summary := make(map[string]interface{})
+		for k, v := range value {
+			if sensitive.MatchString(k) {
+				summary[k] = Redacted
+				continue
+			}
+			summary[k] = summarize(v, depth+1)
+		}
+		return summary
+	case []interface{}:
+		if depth >= MaxArgumentDepth {
+			return fmt.Sprintf("[%d items]", len(value))
+		}
+
+		summary := make([]interface{}, 0, len(value))
+		for _, v := range value {
+			summary = append(summary, summarize(v, depth+1))
+		}
+		return summary
+	case string:
+		if len(value) > MaxArgumentLength {
+			return value[:MaxArgumentLength] + "..."
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+type queryArguments struct {
+	Identity string `json:"identity"`
+	Command  string `json:"command"`
+	Since    int64  `json:"since"`
+	Until    int64  `json:"until"`
+	Limit    int    `json:"limit"`
+}
+
+func (q *queryArguments) match(record *Record) bool {
+	if q.Identity != "" && q.Identity != record.Identity {
+		return false
+	}
+
+	if q.Command != "" {
+		if ok, _ := path.Match(q.Command, record.Command); !ok {
+			return false
+		}
+	}
+
+	if q.Since != 0 && record.Time < q.Since {
+		return false
+	}
+
+	if q.Until != 0 && record.Time > q.Until {
+		return false
+	}
+
+	return true
+}
+
+//query returns the most recent `limit` records that matches the query, oldest first
+func (a *Auditor) query(cmd *pm.Command) (interface{}, error) {
+	var args queryArguments
+	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
+		return nil, err
+	}
+
+	if args.Limit <= 0 {
+		args.Limit = DefaultQueryLimit
+	}
+
+	if args.Command != "" {
+		if _, err := path.Match(args.Command, ""); err != nil {
+			return nil, fmt.Errorf("invalid command pattern: %s", err)
+		}
+	}
+
+	records := make([]Record, 0)
+	for _, name := range []string{a.file + ".1", a.file} {
+		f, err := os.Open(name)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var record Record
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				continue
+			}
+
+			if !args.match(&record) {
+				continue
+			}
+
+			records = append(records, record)
+			if len(records) > args.Limit {
+				records = records[1:]
+			}
+		}
+
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/pm"
+)
+
+func TestSummarize(t *testing.T) {
+	var args interface{}
+	err := json.Unmarshal([]byte(`{
+		"name": "test",
+		"password": "secret",
+		"args": ["a", "b"],
+		"config": {"nested": {"deep": 1}, "token": "abc"},
+		"data": "`+strings.Repeat("x", MaxArgumentLength+10)+`"
+	}`), &args)
+
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	summary := summarize(args, 0).(map[string]interface{})
+
+	assert.Equal(t, "test", summary["name"])
+	assert.Equal(t, Redacted, summary["password"])
+	assert.Equal(t, []interface{}{"a", "b"}, summary["args"])
+	assert.Equal(t, map[string]interface{}{
+		"nested": "{1 keys}",
+		"token":  Redacted,
+	}, summary["config"])
+	assert.Equal(t, strings.Repeat("x", MaxArgumentLength)+"...", summary["data"])
+}
+
+func TestAuditSubmission(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+	defer os.RemoveAll(dir)
+
+	a, err := New(path.Join(dir, "audit.log"))
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	reboot := &pm.Command{ID: "reboot", Command: "core.reboot", Identity: "user", Arguments: pm.MustArguments(nil)}
+	ping := &pm.Command{ID: "ping", Command: "core.ping", Identity: "user", Arguments: pm.MustArguments(nil)}
+	internal := &pm.Command{ID: "internal", Command: "core.ping", Arguments: pm.MustArguments(nil)}
+
+	//the reboot never returns, only its submission is recorded
+	a.Pre(reboot)
+	a.Pre(ping)
+	a.Pre(internal)
+	a.Result(ping, &pm.JobResult{State: pm.StateSuccess})
+	a.Result(internal, &pm.JobResult{State: pm.StateSuccess})
+
+	result, err := a.query(&pm.Command{Arguments: pm.MustArguments(nil)})
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	records := result.([]Record)
+	if !assert.Len(t, records, 3) {
+		t.Fatal()
+	}
+
+	assert.Equal(t, "reboot", records[0].ID)
+	assert.Equal(t, pm.JobState(""), records[0].State)
+	assert.Equal(t, "ping", records[1].ID)
+	assert.Equal(t, pm.JobState(""), records[1].State)
+	assert.Equal(t, "ping", records[2].ID)
+	assert.Equal(t, pm.StateSuccess, records[2].State)
+}
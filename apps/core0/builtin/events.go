@@ -0,0 +1,51 @@
+package builtin
+
+import (
+	"sync"
+	"time"
+
+	"github.com/zero-os/0-core/apps/core0/logger"
+)
+
+const (
+	//EventQueueKey redis queue where the node events are pushed
+	EventQueueKey = "events"
+	//EventQueueSize max number of events kept in the queue
+	EventQueueSize = 1000
+)
+
+//Event is a change of the node state reported by a monitor, like a disk that starts failing
+type Event struct {
+	//Source of the event, it's also the command ID of the event log record
+	Source  string            `json:"source"`
+	Message string            `json:"message"`
+	Tags    map[string]string `json:"tags,omitempty"`
+	Epoch   int64             `json:"epoch"`
+}
+
+var events struct {
+	notifier *logger.Notifier
+	m        sync.Mutex
+}
+
+//SetEvents sets the queue and the logger of the events, events are dropped until they are set
+func SetEvents(queue logger.Queue, records logger.Logger) {
+	events.m.Lock()
+	defer events.m.Unlock()
+
+	events.notifier = logger.NewNotifier(queue, EventQueueKey, EventQueueSize, records)
+}
+
+//emit pushes the event to the events queue, and logs it as a critical message of the event source.
+//Events are dropped until SetEvents is called.
+func emit(event Event) {
+	events.m.Lock()
+	defer events.m.Unlock()
+
+	if events.notifier == nil {
+		return
+	}
+
+	event.Epoch = time.Now().Unix()
+	events.notifier.Notify(event.Source, event.Message, &event)
+}
@@ -23,6 +23,7 @@ const (
 	monitorCPU     = "cpu"
 	monitorNetwork = "network"
 	monitorMemory  = "memory"
+	monitorSensors = "sensors"
 )
 
 var (
@@ -43,12 +44,20 @@ type Pair [2]string
 
 type monitor struct{}
 
+//monitors domains registered by the subsystems
+var monitors = make(map[string]func() error)
+
 func init() {
 	m := (*monitor)(nil)
 
 	pm.RegisterBuiltIn("monitor", m.monitor)
 }
 
+//RegisterMonitor registers a monitoring domain, so subsystems can report their stats with the monitor command
+func RegisterMonitor(domain string, fn func() error) {
+	monitors[domain] = fn
+}
+
 func (m *monitor) monitor(cmd *pm.Command) (interface{}, error) {
 	var args struct {
 		Domain string `json:"domain"`
@@ -67,7 +76,17 @@ func (m *monitor) monitor(cmd *pm.Command) (interface{}, error) {
 		return nil, m.memory()
 	case monitorNetwork:
 		return nil, m.network()
+	case monitorSensors:
+		return nil, m.sensors()
+	case monitorSmart:
+		return nil, m.smart()
+	case monitorProcesses:
+		return nil, m.processes()
 	default:
+		if fn, ok := monitors[strings.ToLower(args.Domain)]; ok {
+			return nil, fn()
+		}
+
 		return nil, fmt.Errorf("invalid monitoring domain: %s", args.Domain)
 	}
 
@@ -308,3 +327,21 @@ func (m *monitor) network() error {
 
 	return nil
 }
+
+func (m *monitor) sensors() error {
+	list, err := sensors(sysClass)
+	if err != nil {
+		return err
+	}
+
+	for _, sensor := range list {
+		pm.Aggregate(pm.AggreagteAverage,
+			fmt.Sprintf("sensors.%s", sensor.Type),
+			sensor.Value,
+			sensor.ID,
+			pm.Tag{"type", "phys"}, pm.Tag{"chip", sensor.Chip}, pm.Tag{"label", sensor.Label},
+		)
+	}
+
+	return nil
+}
@@ -17,6 +17,7 @@ func init() {
 }
 
 func restart(cmd *pm.Command) (interface{}, error) {
+	pm.Shutdown()
 	pm.Killall()
 	syscall.Sync()
 	syscall.Reboot(syscall.LINUX_REBOOT_CMD_RESTART)
@@ -24,6 +25,7 @@ func restart(cmd *pm.Command) (interface{}, error) {
 }
 
 func poweroff(cmd *pm.Command) (interface{}, error) {
+	pm.Shutdown()
 	pm.Killall()
 	syscall.Sync()
 	syscall.Reboot(syscall.LINUX_REBOOT_CMD_POWER_OFF)
@@ -0,0 +1,214 @@
+package builtin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zero-os/0-core/base/pm"
+)
+
+const (
+	cmdGetSensorsInfo = "info.sensors"
+
+	sensorTemperature = "temperature"
+	sensorFan         = "fan"
+	sensorPower       = "power"
+)
+
+var (
+	//sysClass where the hwmon and thermal classes are looked up
+	sysClass = "/sys/class"
+
+	hwmonInputPattern = regexp.MustCompile(`^(temp|fan|power)(\d+)_(input|average)$`)
+
+	//hwmonTypes maps the hwmon file prefixes to the sensor types, and the scale of their values
+	//(millidegree Celsius, RPM and microWatt)
+	hwmonTypes = map[string]struct {
+		Type  string
+		Scale float64
+	}{
+		"temp":  {sensorTemperature, 1000},
+		"fan":   {sensorFan, 1},
+		"power": {sensorPower, 1000000},
+	}
+)
+
+//Sensor reading, temperatures are in degree Celsius, fans in RPM and power in Watt
+type Sensor struct {
+	ID    string  `json:"id"`
+	Type  string  `json:"type"`
+	Chip  string  `json:"chip"`
+	Label string  `json:"label"`
+	Value float64 `json:"value"`
+}
+
+func init() {
+	pm.RegisterBuiltIn(cmdGetSensorsInfo, getSensorsInfo)
+}
+
+func getSensorsInfo(cmd *pm.Command) (interface{}, error) {
+	return sensors(sysClass)
+}
+
+//sensors reads all the hwmon sensors and thermal zones
+func sensors(base string) ([]Sensor, error) {
+	result, err := hwmonSensors(path.Join(base, "hwmon"))
+	if err != nil {
+		return nil, err
+	}
+
+	zones, err := thermalSensors(path.Join(base, "thermal"))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(result, zones...), nil
+}
+
+func readSysValue(name string) (string, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+/*
+hwmonID gets the prefix of the ids of the sensors of a hwmon device. hwmonN is numbered in the order
+the drivers are loaded, so the id is the chip name and the parent device (ex: k10temp-0000:00:18.3), or
+only the chip name for virtual devices.
+*/
+func hwmonID(dir string, chip string) string {
+	link, err := os.Readlink(path.Join(dir, "device"))
+	if err != nil {
+		return chip
+	}
+
+	return fmt.Sprintf("%s-%s", chip, path.Base(link))
+}
+
+func hwmonSensors(base string) ([]Sensor, error) {
+	devices, err := ioutil.ReadDir(base)
+	if err != nil {
+		return nil, nil //no hwmon class, no sensors.
+	}
+
+	var result []Sensor
+	for _, device := range devices {
+		dir := path.Join(base, device.Name())
+		chip, err := readSysValue(path.Join(dir, "name"))
+		if err != nil {
+			//older drivers expose the sensors on the device itself
+			dir = path.Join(dir, "device")
+			if chip, err = readSysValue(path.Join(dir, "name")); err != nil {
+				continue
+			}
+		}
+
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			log.Errorf("failed to list hwmon device %s: %s", device.Name(), err)
+			continue
+		}
+
+		id := hwmonID(path.Join(base, device.Name()), chip)
+
+		//the current value (input) is preferred over the average
+		inputs := make(map[string]string)
+		for _, file := range files {
+			match := hwmonInputPattern.FindStringSubmatch(file.Name())
+			if match == nil {
+				continue
+			}
+
+			sensor := match[1] + match[2]
+			if _, ok := inputs[sensor]; ok && match[3] == "average" {
+				continue
+			}
+
+			inputs[sensor] = file.Name()
+		}
+
+		names := make([]string, 0, len(inputs))
+		for sensor := range inputs {
+			names = append(names, sensor)
+		}
+		sort.Strings(names)
+
+		for _, sensor := range names {
+			raw, err := readSysValue(path.Join(dir, inputs[sensor]))
+			if err != nil {
+				//sensors that are not connected fail to read
+				continue
+			}
+
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				continue
+			}
+
+			label, err := readSysValue(path.Join(dir, sensor+"_label"))
+			if err != nil || label == "" {
+				label = sensor
+			}
+
+			kind := hwmonTypes[strings.TrimRight(sensor, "0123456789")]
+			result = append(result, Sensor{
+				ID:    fmt.Sprintf("%s.%s", id, sensor),
+				Type:  kind.Type,
+				Chip:  chip,
+				Label: label,
+				Value: value / kind.Scale,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+func thermalSensors(base string) ([]Sensor, error) {
+	zones, err := ioutil.ReadDir(base)
+	if err != nil {
+		return nil, nil
+	}
+
+	var result []Sensor
+	for _, zone := range zones {
+		if !strings.HasPrefix(zone.Name(), "thermal_zone") {
+			continue
+		}
+
+		dir := path.Join(base, zone.Name())
+		raw, err := readSysValue(path.Join(dir, "temp"))
+		if err != nil {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			continue
+		}
+
+		label, err := readSysValue(path.Join(dir, "type"))
+		if err != nil || label == "" {
+			label = zone.Name()
+		}
+
+		result = append(result, Sensor{
+			ID:    zone.Name(),
+			Type:  sensorTemperature,
+			Chip:  "thermal",
+			Label: label,
+			Value: value / 1000,
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,75 @@
+package builtin
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSensors(t *testing.T) {
+	base, err := ioutil.TempDir("", "sensors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	files := map[string]string{
+		"hwmon/hwmon0/name":                           "coretemp\n",
+		"hwmon/hwmon0/temp1_input":                    "45000\n",
+		"hwmon/hwmon0/temp1_label":                    "Package id 0\n",
+		"hwmon/hwmon0/temp2_input":                    "43500\n",
+		"devices/platform/nct6775.656/name":           "nct6775\n",
+		"devices/platform/nct6775.656/fan1_input":     "1200\n",
+		"devices/platform/nct6775.656/power1_average": "12500000\n",
+		"hwmon/hwmon2/name":                           "acpitz\n",
+		"hwmon/hwmon2/temp1_input":                    "27800\n",
+		"thermal/thermal_zone0/type":                  "x86_pkg_temp\n",
+		"thermal/thermal_zone0/temp":                  "50000\n",
+		"thermal/cooling_device0/type":                "Processor\n",
+	}
+
+	for name, content := range files {
+		name = path.Join(base, name)
+		if err := os.MkdirAll(path.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//hwmon devices link to their parent device, older drivers expose the sensors on the device itself
+	links := map[string]string{
+		"hwmon/hwmon0/device": "../../devices/platform/coretemp.0",
+		"hwmon/hwmon1/device": "../../devices/platform/nct6775.656",
+	}
+
+	for name, target := range links {
+		name = path.Join(base, name)
+		if err := os.MkdirAll(path.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Symlink(target, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := sensors(base)
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	assert.Equal(t, []Sensor{
+		{ID: "coretemp-coretemp.0.temp1", Type: sensorTemperature, Chip: "coretemp", Label: "Package id 0", Value: 45},
+		{ID: "coretemp-coretemp.0.temp2", Type: sensorTemperature, Chip: "coretemp", Label: "temp2", Value: 43.5},
+		{ID: "nct6775-nct6775.656.fan1", Type: sensorFan, Chip: "nct6775", Label: "fan1", Value: 1200},
+		{ID: "nct6775-nct6775.656.power1", Type: sensorPower, Chip: "nct6775", Label: "power1", Value: 12.5},
+		{ID: "acpitz.temp1", Type: sensorTemperature, Chip: "acpitz", Label: "temp1", Value: 27.8},
+		{ID: "thermal_zone0", Type: sensorTemperature, Chip: "thermal", Label: "x86_pkg_temp", Value: 50},
+	}, result)
+}
@@ -0,0 +1,274 @@
+package builtin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/zero-os/0-core/base/pm"
+	"github.com/zero-os/0-core/base/utils"
+)
+
+const (
+	monitorSmart = "smart"
+	smartEvent   = "disk.smart"
+
+	//smartctlFatal exit status bits, the command line didn't parse or the device could not be opened
+	//(or is in standby). Other bits report the disk health, and the output is still valid.
+	smartctlFatal = 0x03
+)
+
+var (
+	//sysBlock where the block devices are listed
+	sysBlock = "/sys/block"
+
+	//smartCounters are the metrics that only increase as the disk degrades, an event is emitted on
+	//each increase
+	smartCounters = []string{"reallocated", "pending", "media_errors"}
+
+	//last smart report of each disk
+	smartReports = struct {
+		reports map[string]*smartReport
+		m       sync.Mutex
+	}{
+		reports: make(map[string]*smartReport),
+	}
+)
+
+type smartAttribute struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Value  int    `json:"value"`
+	Thresh int    `json:"thresh"`
+	Raw    struct {
+		Value uint64 `json:"value"`
+	} `json:"raw"`
+}
+
+//smartctlOutput is the part of the smartctl json output that is monitored
+type smartctlOutput struct {
+	ModelName    string `json:"model_name"`
+	SerialNumber string `json:"serial_number"`
+	SmartStatus  *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature struct {
+		Current *float64 `json:"current"`
+	} `json:"temperature"`
+	ATAAttributes struct {
+		Table []smartAttribute `json:"table"`
+	} `json:"ata_smart_attributes"`
+	NVMeHealth *struct {
+		CriticalWarning         int    `json:"critical_warning"`
+		AvailableSpare          int    `json:"available_spare"`
+		AvailableSpareThreshold int    `json:"available_spare_threshold"`
+		PercentageUsed          int    `json:"percentage_used"`
+		MediaErrors             uint64 `json:"media_errors"`
+	} `json:"nvme_smart_health_information_log"`
+}
+
+//smartReport is the health of a disk
+type smartReport struct {
+	Model  string
+	Serial string
+	Passed bool
+	//Metrics reallocated, pending, media_errors, wear (percent of the endurance used) and temperature,
+	//only the ones reported by the disk are set
+	Metrics map[string]float64
+	//Failing attributes, at or below their threshold
+	Failing []string
+}
+
+func parseSmartctl(data []byte) (*smartReport, error) {
+	var output smartctlOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, err
+	}
+
+	if output.SmartStatus == nil {
+		return nil, fmt.Errorf("smart is not supported")
+	}
+
+	report := smartReport{
+		Model:   output.ModelName,
+		Serial:  output.SerialNumber,
+		Passed:  output.SmartStatus.Passed,
+		Metrics: make(map[string]float64),
+	}
+
+	if output.Temperature.Current != nil {
+		report.Metrics["temperature"] = *output.Temperature.Current
+	}
+
+	for _, attr := range output.ATAAttributes.Table {
+		switch attr.ID {
+		case 5:
+			report.Metrics["reallocated"] = float64(attr.Raw.Value)
+		case 187:
+			report.Metrics["media_errors"] = float64(attr.Raw.Value)
+		case 197:
+			report.Metrics["pending"] = float64(attr.Raw.Value)
+		case 177, 231, 233:
+			//wear leveling count, ssd life left and media wearout indicator are normalized
+			//from 100 (new) down to 0
+			if _, ok := report.Metrics["wear"]; !ok {
+				report.Metrics["wear"] = float64(100 - attr.Value)
+			}
+		}
+
+		if attr.Thresh > 0 && attr.Value <= attr.Thresh {
+			report.Failing = append(report.Failing, attr.Name)
+		}
+	}
+
+	if health := output.NVMeHealth; health != nil {
+		report.Metrics["media_errors"] = float64(health.MediaErrors)
+		report.Metrics["wear"] = float64(health.PercentageUsed)
+
+		if health.CriticalWarning != 0 {
+			report.Failing = append(report.Failing, "critical_warning")
+		}
+
+		if health.AvailableSpare < health.AvailableSpareThreshold {
+			report.Failing = append(report.Failing, "available_spare")
+		}
+
+		if health.PercentageUsed >= 100 {
+			report.Failing = append(report.Failing, "percentage_used")
+		}
+	}
+
+	sort.Strings(report.Failing)
+	return &report, nil
+}
+
+//physicalDisks lists the block devices backed by a device, which excludes loop, ram, dm and md devices
+func physicalDisks() ([]string, error) {
+	infos, err := ioutil.ReadDir(sysBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	var disks []string
+	for _, info := range infos {
+		if _, err := os.Stat(path.Join(sysBlock, info.Name(), "device")); err != nil {
+			continue
+		}
+
+		disks = append(disks, info.Name())
+	}
+
+	return disks, nil
+}
+
+func smartctl(disk string) (*smartReport, error) {
+	//disks in standby are skipped, so the monitor doesn't spin them up
+	result, err := pm.System("smartctl", "--json=c", "-n", "standby", "-i", "-H", "-A", path.Join("/dev", disk))
+	if err != nil && (result == nil || result.Code&smartctlFatal != 0) {
+		return nil, err
+	}
+
+	return parseSmartctl([]byte(result.Streams.Stdout()))
+}
+
+//smartEvents compares the report of a disk with the previous one, and returns the events of the
+//health and attributes changes
+func smartEvents(disk string, previous, current *smartReport) []Event {
+	if previous == nil {
+		//failing disks are reported at boot
+		previous = &smartReport{Passed: true}
+	}
+
+	tags := map[string]string{
+		"device": disk,
+		"model":  current.Model,
+		"serial": current.Serial,
+	}
+
+	var result []Event
+	add := func(format string, args ...interface{}) {
+		result = append(result, Event{
+			Source:  smartEvent,
+			Message: fmt.Sprintf("disk %s: %s", disk, fmt.Sprintf(format, args...)),
+			Tags:    tags,
+		})
+	}
+
+	if previous.Passed && !current.Passed {
+		add("smart overall health self-assessment failed")
+	} else if !previous.Passed && current.Passed {
+		add("smart overall health self-assessment passed")
+	}
+
+	for _, attr := range current.Failing {
+		if !utils.InString(previous.Failing, attr) {
+			add("attribute %s crossed its threshold", attr)
+		}
+	}
+
+	for _, attr := range previous.Failing {
+		if !utils.InString(current.Failing, attr) {
+			add("attribute %s is back within its threshold", attr)
+		}
+	}
+
+	for _, name := range smartCounters {
+		before, ok := previous.Metrics[name]
+		if !ok {
+			//unknown before the first check
+			continue
+		}
+
+		if after, ok := current.Metrics[name]; ok && after > before {
+			add("%s increased from %v to %v", name, before, after)
+		}
+	}
+
+	return result
+}
+
+func (m *monitor) smart() error {
+	disks, err := physicalDisks()
+	if err != nil {
+		return err
+	}
+
+	for _, disk := range disks {
+		report, err := smartctl(disk)
+		if err != nil {
+			log.Debugf("failed to get smart data of %s: %s", disk, err)
+			continue
+		}
+
+		for name, value := range report.Metrics {
+			op := pm.AggreagteGauge
+			if name == "temperature" {
+				op = pm.AggreagteAverage
+			}
+
+			pm.Aggregate(op, fmt.Sprintf("disk.smart.%s", name), value, disk, pm.Tag{"type", "phys"})
+		}
+
+		var health float64
+		if report.Passed {
+			health = 1
+		}
+
+		pm.Aggregate(pm.AggreagteGauge, "disk.smart.health", health, disk, pm.Tag{"type", "phys"})
+
+		smartReports.m.Lock()
+		previous := smartReports.reports[disk]
+		smartReports.reports[disk] = report
+		smartReports.m.Unlock()
+
+		for _, event := range smartEvents(disk, previous, report) {
+			emit(event)
+		}
+	}
+
+	return nil
+}
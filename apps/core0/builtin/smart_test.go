@@ -0,0 +1,73 @@
+package builtin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	smartctlATA  = `{"model_name":"Samsung SSD 850 EVO","serial_number":"S21","smart_status":{"passed":true},"temperature":{"current":31},"ata_smart_attributes":{"table":[{"id":5,"name":"Reallocated_Sector_Ct","value":100,"thresh":10,"raw":{"value":2}},{"id":177,"name":"Wear_Leveling_Count","value":97,"thresh":0,"raw":{"value":42}},{"id":197,"name":"Current_Pending_Sector","value":100,"thresh":0,"raw":{"value":1}},{"id":9,"name":"Power_On_Hours","value":5,"thresh":10,"raw":{"value":9000}}]}}`
+	smartctlNVMe = `{"model_name":"INTEL SSDPE2KX010T8","serial_number":"PHLJ","smart_status":{"passed":false},"temperature":{"current":40},"nvme_smart_health_information_log":{"critical_warning":1,"available_spare":5,"available_spare_threshold":10,"percentage_used":3,"media_errors":7}}`
+)
+
+func TestParseSmartctl(t *testing.T) {
+	report, err := parseSmartctl([]byte(smartctlATA))
+	if assert.NoError(t, err) {
+		assert.True(t, report.Passed)
+		assert.Equal(t, "S21", report.Serial)
+		assert.Equal(t, map[string]float64{
+			"temperature": 31,
+			"reallocated": 2,
+			"pending":     1,
+			"wear":        3,
+		}, report.Metrics)
+		assert.Equal(t, []string{"Power_On_Hours"}, report.Failing)
+	}
+
+	report, err = parseSmartctl([]byte(smartctlNVMe))
+	if assert.NoError(t, err) {
+		assert.False(t, report.Passed)
+		assert.Equal(t, map[string]float64{
+			"temperature":  40,
+			"media_errors": 7,
+			"wear":         3,
+		}, report.Metrics)
+		assert.Equal(t, []string{"available_spare", "critical_warning"}, report.Failing)
+	}
+
+	_, err = parseSmartctl([]byte(`{"device":{"name":"/dev/vda"}}`))
+	assert.Error(t, err)
+}
+
+func TestSmartEvents(t *testing.T) {
+	healthy := &smartReport{Passed: true}
+	failing := &smartReport{Passed: false, Failing: []string{"Reallocated_Sector_Ct"}}
+
+	assert.Len(t, smartEvents("sda", nil, healthy), 0)
+	assert.Len(t, smartEvents("sda", healthy, healthy), 0)
+
+	events := smartEvents("sda", healthy, failing)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "disk sda: smart overall health self-assessment failed", events[0].Message)
+		assert.Equal(t, "disk sda: attribute Reallocated_Sector_Ct crossed its threshold", events[1].Message)
+		assert.Equal(t, smartEvent, events[0].Source)
+		assert.Equal(t, "sda", events[0].Tags["device"])
+	}
+
+	//failing disks are reported on the first check
+	assert.Len(t, smartEvents("sda", nil, failing), 2)
+	assert.Len(t, smartEvents("sda", failing, failing), 0)
+	assert.Len(t, smartEvents("sda", failing, healthy), 2)
+
+	//increases of the counters are reported
+	before := &smartReport{Passed: true, Metrics: map[string]float64{"reallocated": 2, "pending": 1, "temperature": 30}}
+	after := &smartReport{Passed: true, Metrics: map[string]float64{"reallocated": 5, "pending": 1, "temperature": 40}}
+
+	assert.Len(t, smartEvents("sda", nil, after), 0)
+	assert.Len(t, smartEvents("sda", after, before), 0)
+	events = smartEvents("sda", before, after)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "disk sda: reallocated increased from 2 to 5", events[0].Message)
+	}
+}
@@ -0,0 +1,420 @@
+package builtin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/shirou/gopsutil/process"
+	"github.com/zero-os/0-core/base/pm"
+)
+
+const (
+	monitorProcesses = "processes"
+	cmdProcessTop    = "process.top"
+
+	defaultTopLimit = 10
+
+	topCPU   = "cpu"
+	topRSS   = "rss"
+	topRead  = "read"
+	topWrite = "write"
+	topIO    = "io"
+	topFDs   = "fds"
+)
+
+var (
+	//containerJobPattern matches the job of the container core process, all the processes of a
+	//container are its children
+	containerJobPattern = regexp.MustCompile(`^core-(\d+)$`)
+
+	topSort = map[string]func(p *ProcessUsage) float64{
+		topCPU:   func(p *ProcessUsage) float64 { return p.CPU },
+		topRSS:   func(p *ProcessUsage) float64 { return float64(p.RSS) },
+		topRead:  func(p *ProcessUsage) float64 { return p.Read },
+		topWrite: func(p *ProcessUsage) float64 { return p.Write },
+		topIO:    func(p *ProcessUsage) float64 { return p.Read + p.Write },
+		topFDs:   func(p *ProcessUsage) float64 { return float64(p.FDs) },
+	}
+
+	processes = processSampler{
+		last: make(map[int32]*processSample),
+	}
+)
+
+//processSample is the raw counters of a process
+type processSample struct {
+	PID        int32
+	Job        string
+	Name       string
+	CreateTime int64
+	//CPU time in seconds
+	CPU        float64
+	RSS        uint64
+	ReadBytes  uint64
+	WriteBytes uint64
+	FDs        int32
+}
+
+//ProcessUsage is the resources usage of a process over the last sampling interval
+type ProcessUsage struct {
+	PID       int32  `json:"pid"`
+	Name      string `json:"name"`
+	Job       string `json:"job"`
+	Container uint64 `json:"container,omitempty"`
+	//CPU percent, can go above 100 for multi-threaded processes
+	CPU float64 `json:"cpu"`
+	//RSS in bytes
+	RSS uint64 `json:"rss"`
+	//Read and Write rates in bytes per second
+	Read  float64 `json:"read"`
+	Write float64 `json:"write"`
+	FDs   int32   `json:"fds"`
+}
+
+type processSampler struct {
+	last     map[int32]*processSample
+	lastTime time.Time
+	usage    []ProcessUsage
+	interval time.Duration
+	m        sync.Mutex
+}
+
+func init() {
+	pm.RegisterBuiltIn(cmdProcessTop, processTop)
+}
+
+//jobContainer gets the container ID of a container core job
+func jobContainer(job string) uint64 {
+	match := containerJobPattern.FindStringSubmatch(job)
+	if match == nil {
+		return 0
+	}
+
+	id, _ := strconv.ParseUint(match[1], 10, 64)
+	return id
+}
+
+/*
+stableJob checks if the usage of a job can be aggregated under its ID. Jobs submitted without an ID get
+a random one, which would create new metrics on every run, so only the containers and the named or
+recurring jobs are aggregated. The usage of the other jobs is only listed by process.top.
+*/
+func stableJob(id string) bool {
+	if jobContainer(id) != 0 || uuid.Parse(id) == nil {
+		return true
+	}
+
+	job, ok := pm.JobOf(id)
+	return ok && job.Command().RecurringPeriod > 0
+}
+
+//processTree maps the running processes to their children
+func processTree() (map[int32][]int32, error) {
+	infos, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	tree := make(map[int32][]int32)
+	for _, info := range infos {
+		pid, err := strconv.ParseInt(info.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			//process is gone
+			continue
+		}
+
+		//the command name is between parentheses and can have spaces, the ppid is the
+		//second field after it
+		stat := string(data)
+		fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+		if len(fields) < 2 {
+			continue
+		}
+
+		ppid, err := strconv.ParseInt(fields[1], 10, 32)
+		if err != nil {
+			continue
+		}
+
+		tree[int32(ppid)] = append(tree[int32(ppid)], int32(pid))
+	}
+
+	return tree, nil
+}
+
+//jobProcesses maps the processes of all jobs and their children to the job ID
+func jobProcesses() (map[int32]string, error) {
+	tree, err := processTree()
+	if err != nil {
+		return nil, err
+	}
+
+	pids := make(map[int32]string)
+	for id, job := range pm.Jobs() {
+		pider, ok := job.Process().(pm.PIDer)
+		if !ok || pider.PID() == 0 {
+			continue
+		}
+
+		queue := []int32{int32(pider.PID())}
+		for len(queue) != 0 {
+			pid := queue[0]
+			queue = queue[1:]
+
+			pids[pid] = id
+			queue = append(queue, tree[pid]...)
+		}
+	}
+
+	return pids, nil
+}
+
+func sampleProcess(pid int32, job string) (*processSample, error) {
+	ps, err := process.NewProcess(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	sample := processSample{PID: pid, Job: job}
+	if sample.CreateTime, err = ps.CreateTime(); err != nil {
+		return nil, err
+	}
+
+	if name, err := ps.Name(); err == nil {
+		sample.Name = name
+	}
+
+	if times, err := ps.Times(); err == nil {
+		sample.CPU = times.User + times.System
+	}
+
+	if mem, err := ps.MemoryInfo(); err == nil {
+		sample.RSS = mem.RSS
+	}
+
+	if io, err := ps.IOCounters(); err == nil {
+		sample.ReadBytes = io.ReadBytes
+		sample.WriteBytes = io.WriteBytes
+	}
+
+	if fds, err := ps.NumFDs(); err == nil {
+		sample.FDs = fds
+	}
+
+	return &sample, nil
+}
+
+//processUsage computes the usage of a process since its previous sample, processes that
+//started during the interval are compared to zero counters
+func processUsage(previous, current *processSample, interval time.Duration) ProcessUsage {
+	if previous == nil || previous.CreateTime != current.CreateTime {
+		//new process, or the pid was reused
+		previous = &processSample{}
+	}
+
+	usage := ProcessUsage{
+		PID:       current.PID,
+		Name:      current.Name,
+		Job:       current.Job,
+		Container: jobContainer(current.Job),
+		RSS:       current.RSS,
+		FDs:       current.FDs,
+	}
+
+	seconds := interval.Seconds()
+	if seconds <= 0 {
+		return usage
+	}
+
+	if current.CPU > previous.CPU {
+		usage.CPU = (current.CPU - previous.CPU) / seconds * 100
+	}
+
+	if current.ReadBytes > previous.ReadBytes {
+		usage.Read = float64(current.ReadBytes-previous.ReadBytes) / seconds
+	}
+
+	if current.WriteBytes > previous.WriteBytes {
+		usage.Write = float64(current.WriteBytes-previous.WriteBytes) / seconds
+	}
+
+	return usage
+}
+
+//sample samples all the jobs processes, and updates the usage of the last interval. The first
+//sample only sets the reference counters.
+func (s *processSampler) sample() ([]ProcessUsage, error) {
+	pids, err := jobProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	samples := make(map[int32]*processSample)
+	for pid, job := range pids {
+		sample, err := sampleProcess(pid, job)
+		if err != nil {
+			continue
+		}
+
+		samples[pid] = sample
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	first := s.lastTime.IsZero()
+	interval := now.Sub(s.lastTime)
+
+	var usage []ProcessUsage
+	if !first {
+		usage = make([]ProcessUsage, 0, len(samples))
+		for pid, sample := range samples {
+			usage = append(usage, processUsage(s.last[pid], sample, interval))
+		}
+	}
+
+	s.last, s.lastTime = samples, now
+	s.usage, s.interval = usage, interval
+
+	return usage, nil
+}
+
+//top gets the limit processes with the highest usage of the resource
+func top(usage []ProcessUsage, resource string, limit int) ([]ProcessUsage, error) {
+	key, ok := topSort[resource]
+	if !ok {
+		return nil, fmt.Errorf("invalid resource '%s'", resource)
+	}
+
+	sorted := make([]ProcessUsage, len(usage))
+	copy(sorted, usage)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return key(&sorted[i]) > key(&sorted[j])
+	})
+
+	if limit > 0 && len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+
+	return sorted, nil
+}
+
+func (m *monitor) processes() error {
+	usage, err := processes.sample()
+	if err != nil {
+		return err
+	}
+
+	type total struct {
+		ProcessUsage
+		Count int
+	}
+
+	jobs := make(map[string]*total)
+	for _, p := range usage {
+		if !stableJob(p.Job) {
+			continue
+		}
+
+		job, ok := jobs[p.Job]
+		if !ok {
+			job = &total{ProcessUsage: ProcessUsage{Job: p.Job, Container: p.Container}}
+			jobs[p.Job] = job
+		}
+
+		job.CPU += p.CPU
+		job.RSS += p.RSS
+		job.Read += p.Read
+		job.Write += p.Write
+		job.FDs += p.FDs
+		job.Count++
+	}
+
+	for id, job := range jobs {
+		tags := []pm.Tag{{"type", "phys"}}
+		if job.Container != 0 {
+			tags = append(tags, pm.Tag{"container", fmt.Sprint(job.Container)})
+		}
+
+		pm.Aggregate(pm.AggreagteAverage, "process.CPU.percent", job.CPU, id, tags...)
+		pm.Aggregate(pm.AggreagteAverage, "process.memory.rss", float64(job.RSS)/(1024.*1024.), id, tags...)
+		pm.Aggregate(pm.AggreagteAverage, "process.io.read", job.Read/1024., id, tags...)
+		pm.Aggregate(pm.AggreagteAverage, "process.io.write", job.Write/1024., id, tags...)
+		pm.Aggregate(pm.AggreagteAverage, "process.fds", float64(job.FDs), id, tags...)
+		pm.Aggregate(pm.AggreagteAverage, "process.count", float64(job.Count), id, tags...)
+	}
+
+	return nil
+}
+
+type processTopArguments struct {
+	Sort  string `json:"sort"`
+	Limit int    `json:"limit"`
+	Job   string `json:"job"`
+	//Container filters the processes of a container
+	Container uint64 `json:"container"`
+}
+
+//process.top
+func processTop(cmd *pm.Command) (interface{}, error) {
+	var args processTopArguments
+	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
+		return nil, err
+	}
+
+	if args.Sort == "" {
+		args.Sort = topCPU
+	}
+
+	if args.Limit == 0 {
+		args.Limit = defaultTopLimit
+	}
+
+	processes.m.Lock()
+	usage, interval := processes.usage, processes.interval
+	processes.m.Unlock()
+
+	if usage == nil {
+		return nil, fmt.Errorf("no processes usage yet, the %s monitor has not run twice", monitorProcesses)
+	}
+
+	filtered := make([]ProcessUsage, 0, len(usage))
+	for _, p := range usage {
+		if args.Job != "" && p.Job != args.Job {
+			continue
+		}
+
+		if args.Container != 0 && p.Container != args.Container {
+			continue
+		}
+
+		filtered = append(filtered, p)
+	}
+
+	result, err := top(filtered, strings.ToLower(args.Sort), args.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		Interval  float64        `json:"interval"`
+		Processes []ProcessUsage `json:"processes"`
+	}{
+		Interval:  interval.Seconds(),
+		Processes: result,
+	}, nil
+}
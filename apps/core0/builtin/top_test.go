@@ -0,0 +1,67 @@
+package builtin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobContainer(t *testing.T) {
+	assert.Equal(t, uint64(12), jobContainer("core-12"))
+	assert.Equal(t, uint64(0), jobContainer("core-x"))
+	assert.Equal(t, uint64(0), jobContainer("monitor.cpu"))
+}
+
+func TestStableJob(t *testing.T) {
+	assert.True(t, stableJob("core-12"))
+	assert.True(t, stableJob("monitor.cpu"))
+	assert.False(t, stableJob(uuid.New()))
+}
+
+func TestProcessUsage(t *testing.T) {
+	previous := &processSample{PID: 10, CreateTime: 1000, CPU: 10, ReadBytes: 1000, WriteBytes: 5000}
+	current := &processSample{PID: 10, Job: "core-3", CreateTime: 1000, CPU: 25, RSS: 4096, ReadBytes: 4000, WriteBytes: 5000, FDs: 8}
+
+	assert.Equal(t, ProcessUsage{
+		PID:       10,
+		Job:       "core-3",
+		Container: 3,
+		CPU:       50,
+		RSS:       4096,
+		Read:      100,
+		FDs:       8,
+	}, processUsage(previous, current, 30*time.Second))
+
+	//the pid was reused, the counters are compared to zero
+	previous.CreateTime = 500
+	usage := processUsage(previous, current, 30*time.Second)
+	assert.InDelta(t, 83.33, usage.CPU, 0.01)
+	assert.Equal(t, 5000./30., usage.Write)
+}
+
+func TestTop(t *testing.T) {
+	usage := []ProcessUsage{
+		{PID: 1, CPU: 10, RSS: 300, Read: 5},
+		{PID: 2, CPU: 50, RSS: 100, Write: 20},
+		{PID: 3, CPU: 30, RSS: 200, Read: 1},
+	}
+
+	result, err := top(usage, topCPU, 2)
+	if assert.NoError(t, err) && assert.Len(t, result, 2) {
+		assert.Equal(t, int32(2), result[0].PID)
+		assert.Equal(t, int32(3), result[1].PID)
+	}
+
+	result, err = top(usage, topIO, 0)
+	if assert.NoError(t, err) && assert.Len(t, result, 3) {
+		assert.Equal(t, []int32{2, 1, 3}, []int32{result[0].PID, result[1].PID, result[2].PID})
+	}
+
+	//the input is not sorted in place
+	assert.Equal(t, int32(1), usage[0].PID)
+
+	_, err = top(usage, "disk", 1)
+	assert.Error(t, err)
+}
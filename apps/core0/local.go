@@ -10,6 +10,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"syscall"
 )
 
 type Local struct {
@@ -56,6 +57,39 @@ func (l *Local) container(x string) containers.Container {
 	return l.mgr.GetOneWithTags(tags...)
 }
 
+//identity of the peer process, as `uid:<uid>`
+func (l *Local) identity(con net.Conn) string {
+	unix, ok := con.(*net.UnixConn)
+	if !ok {
+		return ""
+	}
+
+	//the credentials are read on the connection fd itself, File() would dup it and switch the
+	//connection to blocking mode
+	raw, err := unix.SyscallConn()
+	if err != nil {
+		log.Errorf("failed to get local transport peer: %s", err)
+		return ""
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+
+	if err == nil {
+		err = credErr
+	}
+
+	if err != nil {
+		log.Errorf("failed to get local transport peer credentials: %s", err)
+		return ""
+	}
+
+	return fmt.Sprintf("uid:%d", cred.Uid)
+}
+
 func (l *Local) server(con net.Conn) {
 	//read command
 	result := &pm.JobResult{
@@ -85,6 +119,8 @@ func (l *Local) server(con net.Conn) {
 		return
 	}
 
+	cmd.Identity = l.identity(con)
+
 	container := l.container(lcmd.Container)
 
 	if lcmd.Container != "" && container == nil {
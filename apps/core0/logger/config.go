@@ -17,11 +17,16 @@ type Loggers []Logger
 func (l Loggers) Message(cmd *pm.Command, msg *stream.Message) {
 	l.LogRecord(&LogRecord{
 		Command: cmd.ID,
+		Tags:    cmd.Tags,
 		Message: msg,
 	})
 }
 
 func (l Loggers) LogRecord(record *LogRecord) {
+	if record.Fields == nil {
+		record.Fields = parseFields(record.Message)
+	}
+
 	for _, logger := range l {
 		logger.LogRecord(record)
 	}
@@ -35,5 +40,34 @@ func ConfigureLogging(sink *transport.Sink) {
 		NewStreamLogger(sink, 0),
 	)
 
+	if store := settings.Settings.Logging.Store; store.Enabled {
+		if logger, err := NewStoreLogger(store.Dir, store.Levels, store.Size); err != nil {
+			log.Errorf("failed to initialize log store: %s", err)
+		} else {
+			Current = append(Current, logger)
+		}
+	}
+
+	//the syslog forwarder is always created, so it can be enabled at runtime
+	syslog := settings.Settings.Logging.Syslog
+	config := SyslogConfig{
+		Enabled:   syslog.Enabled,
+		Address:   syslog.Address,
+		Levels:    syslog.Levels,
+		Facility:  syslog.Facility,
+		CA:        syslog.CA,
+		Spool:     syslog.Spool,
+		SpoolSize: syslog.SpoolSize,
+	}
+
+	logger, err := NewSyslogLogger(config)
+	if err != nil {
+		log.Errorf("invalid syslog configuration, syslog forwarding is disabled: %s", err)
+		config.Enabled = false
+		logger, _ = NewSyslogLogger(config)
+	}
+
+	Current = append(Current, logger)
+
 	pm.AddHandle(Current)
 }
@@ -0,0 +1,169 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zero-os/0-core/base/pm/stream"
+)
+
+//fieldFilterPattern matches field filters like `component=zdb` or `severity>=warn`
+var fieldFilterPattern = regexp.MustCompile(`^([\w.-]+)\s*(=|!=|>=|<=|>|<)\s*(.*)$`)
+
+//severityRanks orders the common severity names, so fields like severity>=warn can be compared
+var severityRanks = map[string]int{
+	"trace":     0,
+	"debug":     1,
+	"info":      2,
+	"notice":    3,
+	"warn":      4,
+	"warning":   4,
+	"error":     5,
+	"err":       5,
+	"critical":  6,
+	"crit":      6,
+	"fatal":     7,
+	"alert":     7,
+	"emergency": 8,
+	"emerg":     8,
+	"panic":     8,
+}
+
+//parseFields gets the fields of a structured (level 6) message, it returns nil if the
+//message is not a json object
+func parseFields(msg *stream.Message) map[string]interface{} {
+	if msg == nil || msg.Meta.Level() != stream.LevelStructured {
+		return nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(msg.Message), &fields); err != nil {
+		return nil
+	}
+
+	return fields
+}
+
+//fieldFilter filters records on the value of a structured message field
+type fieldFilter struct {
+	Field string
+	Op    string
+	Value string
+}
+
+func parseFieldFilter(filter string) (fieldFilter, error) {
+	match := fieldFilterPattern.FindStringSubmatch(strings.TrimSpace(filter))
+	if match == nil {
+		return fieldFilter{}, fmt.Errorf("invalid field filter '%s'", filter)
+	}
+
+	return fieldFilter{Field: match[1], Op: match[2], Value: match[3]}, nil
+}
+
+func parseFieldFilters(filters []string) ([]fieldFilter, error) {
+	var parsed []fieldFilter
+	for _, filter := range filters {
+		f, err := parseFieldFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed = append(parsed, f)
+	}
+
+	return parsed, nil
+}
+
+//lookup gets a field value, nested fields are separated with a dot
+func lookup(fields map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := fields[name]; ok {
+		return value, true
+	}
+
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
+
+	nested, ok := fields[parts[0]].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	return lookup(nested, parts[1])
+}
+
+//compare compares two values as numbers, or as severities, and falls back to comparing them
+//as strings
+func compare(a, b string) int {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+
+	rankA, okA := severityRanks[strings.ToLower(a)]
+	rankB, okB := severityRanks[strings.ToLower(b)]
+	if okA && okB {
+		return rankA - rankB
+	}
+
+	return strings.Compare(a, b)
+}
+
+func (f *fieldFilter) match(fields map[string]interface{}) bool {
+	raw, ok := lookup(fields, f.Field)
+	if !ok {
+		//a missing field only matches !=
+		return f.Op == "!="
+	}
+
+	var value string
+	switch raw := raw.(type) {
+	case string:
+		value = raw
+	case float64:
+		value = strconv.FormatFloat(raw, 'f', -1, 64)
+	default:
+		data, _ := json.Marshal(raw)
+		value = string(data)
+	}
+
+	c := compare(value, f.Value)
+	switch f.Op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+
+	return false
+}
+
+//matchFields checks that the record fields matches all the filters
+func matchFields(filters []fieldFilter, record *LogRecord) bool {
+	for i := range filters {
+		if !filters[i].match(record.Fields) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/plugin"
+	"github.com/zero-os/0-core/base/pm/stream"
+)
+
+func TestParseFields(t *testing.T) {
+	fields := parseFields(&stream.Message{
+		Message: `{"component": "zdb", "size": 10}`,
+		Meta:    stream.NewMeta(stream.LevelStructured),
+	})
+
+	assert.Equal(t, map[string]interface{}{"component": "zdb", "size": 10.0}, fields)
+
+	assert.Nil(t, parseFields(&stream.Message{
+		Message: `{"component": "zdb"}`,
+		Meta:    stream.NewMeta(stream.LevelStdout),
+	}))
+
+	assert.Nil(t, parseFields(&stream.Message{
+		Message: `not json`,
+		Meta:    stream.NewMeta(stream.LevelStructured),
+	}))
+}
+
+func TestFieldFilters(t *testing.T) {
+	record := &LogRecord{
+		Fields: map[string]interface{}{
+			"component": "zdb",
+			"severity":  "error",
+			"size":      150.0,
+			"disk":      map[string]interface{}{"name": "sda"},
+		},
+	}
+
+	cases := []struct {
+		filter string
+		match  bool
+	}{
+		{"component=zdb", true},
+		{"component!=zdb", false},
+		{"severity>=warn", true},
+		{"severity<warn", false},
+		{"size>100", true},
+		{"size<=100", false},
+		{"disk.name=sda", true},
+		{"missing=value", false},
+		{"missing!=value", true},
+	}
+
+	for _, c := range cases {
+		filters, err := parseFieldFilters([]string{c.filter})
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		assert.Equal(t, c.match, matchFields(filters, record), c.filter)
+	}
+
+	_, err := parseFieldFilters([]string{"no operator"})
+	assert.Error(t, err)
+}
+
+func TestPluginLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := plugin.NewLogger(&buf, plugin.Fields{"component": "zdb"})
+	if !assert.NoError(t, logger.Warn("disk is almost full", plugin.Fields{"usage": 95})) {
+		t.Fatal()
+	}
+
+	line := strings.TrimSpace(buf.String())
+	if !assert.True(t, strings.HasPrefix(line, "6::")) {
+		t.Fatal()
+	}
+
+	fields := parseFields(&stream.Message{
+		Message: strings.TrimPrefix(line, "6::"),
+		Meta:    stream.NewMeta(stream.LevelStructured),
+	})
+
+	filters, _ := parseFieldFilters([]string{"component=zdb", "severity>=warn", "usage>90"})
+	assert.True(t, matchFields(filters, &LogRecord{Fields: fields}))
+}
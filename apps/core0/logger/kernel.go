@@ -0,0 +1,297 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/zero-os/0-core/base/pm"
+	"github.com/zero-os/0-core/base/pm/stream"
+)
+
+const (
+	//KernelCommandID is the command ID of the kernel logs
+	KernelCommandID = "kernel"
+
+	kmsgDevice = "/dev/kmsg"
+	//kmsgMaxRecord max size of a single kernel record
+	kmsgMaxRecord = 8192
+	//kmsgMaxDepth max number of ancestors checked to find the job of a process
+	kmsgMaxDepth = 64
+)
+
+//kernelLevels maps kernel log priorities to log levels
+var kernelLevels = map[int]uint16{
+	0: stream.LevelCritical, //emerg
+	1: stream.LevelCritical, //alert
+	2: stream.LevelCritical, //crit
+	3: stream.LevelOpsError, //err
+	4: stream.LevelWarning,  //warning
+	5: stream.LevelOperator, //notice
+	6: stream.LevelStdout,   //info
+	7: stream.LevelDebug,    //debug
+}
+
+var (
+	//oomKilled matches the OOM killer report of the killed process
+	oomKilled = regexp.MustCompile(`Killed process (\d+) \(([^)]*)\)`)
+	//oomKill matches the OOM killer summary logged right before the report, it has the memory
+	//cgroup of the killed process
+	oomKill = regexp.MustCompile(`oom-kill:.*task_memcg=([^,]*),task=[^,]*,pid=(\d+)`)
+	//containerMemcg matches the memory accounting cgroup of a container
+	containerMemcg = regexp.MustCompile(`^/core-(\d+)(/|$)`)
+)
+
+type kmsgRecord struct {
+	Priority int
+	Sequence uint64
+	//Timestamp since boot
+	Timestamp time.Duration
+	Message   string
+}
+
+//parseKmsg parses a single /dev/kmsg record "priority,sequence,timestamp,flags;message"
+//followed by optional continuation lines that are ignored.
+func parseKmsg(data []byte) (*kmsgRecord, error) {
+	if nl := bytes.IndexByte(data, '\n'); nl >= 0 {
+		data = data[:nl]
+	}
+
+	semi := bytes.IndexByte(data, ';')
+	if semi < 0 {
+		return nil, fmt.Errorf("invalid kernel record: %s", data)
+	}
+
+	fields := strings.Split(string(data[:semi]), ",")
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("invalid kernel record header: %s", data[:semi])
+	}
+
+	priority, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, err
+	}
+
+	sequence, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	usec, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kmsgRecord{
+		//lower 3 bits are the priority, the rest is the facility
+		Priority:  priority & 7,
+		Sequence:  sequence,
+		Timestamp: time.Duration(usec) * time.Microsecond,
+		Message:   string(data[semi+1:]),
+	}, nil
+}
+
+//owner finds the container and the job of a process, from the memory cgroup of the process if known
+type owner func(pid int, memcg string) (core uint16, cmd *pm.Command, ok bool)
+
+//kernelParser converts kernel records to log records, it keeps the memory cgroup of the last
+//process reported by the OOM killer until its kill record is parsed
+type kernelParser struct {
+	boot time.Time
+	find owner
+
+	oomPID   int
+	oomMemcg string
+}
+
+func newKernelParser(boot time.Time, find owner) *kernelParser {
+	return &kernelParser{
+		boot: boot,
+		find: find,
+	}
+}
+
+//records converts a kernel record to log records. An extra record is logged for processes killed
+//by the OOM killer, attributed to the job or container of the killed process if it's known.
+func (p *kernelParser) records(record *kmsgRecord) []*LogRecord {
+	level, ok := kernelLevels[record.Priority]
+	if !ok {
+		level = stream.LevelStdout
+	}
+
+	epoch := p.boot.Add(record.Timestamp).UnixNano()
+	records := []*LogRecord{
+		{
+			Command: KernelCommandID,
+			Message: &stream.Message{
+				Message: record.Message,
+				Epoch:   epoch,
+				Meta:    stream.NewMeta(level),
+			},
+		},
+	}
+
+	if match := oomKill.FindStringSubmatch(record.Message); match != nil {
+		p.oomPID, _ = strconv.Atoi(match[2])
+		p.oomMemcg = match[1]
+		return records
+	}
+
+	match := oomKilled.FindStringSubmatch(record.Message)
+	if match == nil {
+		return records
+	}
+
+	pid, _ := strconv.Atoi(match[1])
+	var memcg string
+	if pid == p.oomPID {
+		memcg = p.oomMemcg
+	}
+
+	message := fmt.Sprintf("process %d (%s) was killed by the kernel OOM killer", pid, match[2])
+	if memcg != "" {
+		message = fmt.Sprintf("%s (memory cgroup %s)", message, memcg)
+	}
+
+	oom := &LogRecord{
+		Command: KernelCommandID,
+		Message: &stream.Message{
+			Message: message,
+			Epoch:   epoch,
+			Meta:    stream.NewMeta(stream.LevelCritical),
+		},
+	}
+
+	if core, cmd, ok := p.find(pid, memcg); ok {
+		oom.Core = core
+		if cmd != nil {
+			oom.Command = cmd.ID
+			oom.Tags = cmd.Tags
+		}
+	}
+
+	return append(records, oom)
+}
+
+//parent gets the parent PID of a process from /proc
+func parent(pid int) (int, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+
+	//the process name can have spaces and parenthesis, so fields are counted after the last ')'
+	end := bytes.LastIndexByte(data, ')')
+	if end < 0 {
+		return 0, fmt.Errorf("invalid stat of process %d", pid)
+	}
+
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("invalid stat of process %d", pid)
+	}
+
+	return strconv.Atoi(fields[1])
+}
+
+//jobOwner finds the job of a process. Processes in the memory cgroup of a container are attributed to
+//the container, this works even after the killed process is reaped. Otherwise the process is matched
+//to the jobs, or to one of its ancestors which only works as long as the process is not reaped yet.
+func jobOwner(pid int, memcg string) (uint16, *pm.Command, bool) {
+	if match := containerMemcg.FindStringSubmatch(memcg); match != nil {
+		core, _ := strconv.ParseUint(match[1], 10, 16)
+		return uint16(core), nil, true
+	}
+
+	pids := make(map[int]*pm.Command)
+	for _, job := range pm.Jobs() {
+		if process, ok := job.Process().(pm.PIDer); ok && process.PID() > 0 {
+			pids[process.PID()] = job.Command()
+		}
+	}
+
+	for depth := 0; pid > 1 && depth < kmsgMaxDepth; depth++ {
+		if cmd, ok := pids[pid]; ok {
+			var core uint16
+			if _, err := fmt.Sscanf(cmd.ID, "core-%d", &core); err == nil {
+				return core, nil, true
+			}
+
+			return 0, cmd, true
+		}
+
+		var err error
+		if pid, err = parent(pid); err != nil {
+			return 0, nil, false
+		}
+	}
+
+	return 0, nil, false
+}
+
+//bootTime gets the wall clock time of the boot, kernel records timestamps are relative to it
+func bootTime() time.Time {
+	now := time.Now()
+	data, err := ioutil.ReadFile("/proc/uptime")
+	if err != nil {
+		return now
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return now
+	}
+
+	uptime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return now
+	}
+
+	return now.Add(-time.Duration(uptime * float64(time.Second)))
+}
+
+//KernelLogs forwards the kernel logs (/dev/kmsg) to the current loggers, including the logs
+//written since boot
+func KernelLogs() error {
+	kmsg, err := os.Open(kmsgDevice)
+	if err != nil {
+		return err
+	}
+
+	go readKernelLogs(kmsg, bootTime())
+	return nil
+}
+
+func readKernelLogs(kmsg *os.File, boot time.Time) {
+	defer kmsg.Close()
+
+	parser := newKernelParser(boot, jobOwner)
+	buf := make([]byte, kmsgMaxRecord)
+	for {
+		//each read returns a single record
+		n, err := kmsg.Read(buf)
+		if perr, ok := err.(*os.PathError); ok && perr.Err == syscall.EPIPE {
+			log.Warningf("kernel logs were overwritten before they were read")
+			continue
+		} else if err != nil {
+			log.Errorf("failed to read kernel logs: %s", err)
+			return
+		}
+
+		record, err := parseKmsg(buf[:n])
+		if err != nil {
+			log.Debugf("%s", err)
+			continue
+		}
+
+		for _, record := range parser.records(record) {
+			Current.LogRecord(record)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/pm"
+	"github.com/zero-os/0-core/base/pm/stream"
+)
+
+func TestParseKmsg(t *testing.T) {
+	record, err := parseKmsg([]byte("30,1234,5140900,-;eth0: link down\n SUBSYSTEM=net\n DEVICE=n2\n"))
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	assert.Equal(t, 6, record.Priority)
+	assert.Equal(t, uint64(1234), record.Sequence)
+	assert.Equal(t, 5140900*time.Microsecond, record.Timestamp)
+	assert.Equal(t, "eth0: link down", record.Message)
+
+	_, err = parseKmsg([]byte("invalid record"))
+	assert.Error(t, err)
+}
+
+func TestKernelRecords(t *testing.T) {
+	boot := time.Unix(1000, 0)
+	record := &kmsgRecord{Priority: 3, Timestamp: time.Second, Message: "blk_update_request: I/O error, dev sda, sector 0"}
+
+	records := newKernelParser(boot, nil).records(record)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, KernelCommandID, records[0].Command)
+		assert.Equal(t, stream.LevelOpsError, records[0].Message.Meta.Level())
+		assert.Equal(t, time.Unix(1001, 0).UnixNano(), records[0].Message.Epoch)
+	}
+
+	oom := &kmsgRecord{Priority: 3, Message: "Out of memory: Killed process 42 (stress) total-vm:1024kB, anon-rss:512kB"}
+
+	//job in core0
+	cmd := &pm.Command{ID: "job-1", Tags: pm.Tags{"test"}}
+	records = newKernelParser(boot, func(pid int, memcg string) (uint16, *pm.Command, bool) {
+		assert.Equal(t, 42, pid)
+		return 0, cmd, true
+	}).records(oom)
+
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, "job-1", records[1].Command)
+		assert.Equal(t, cmd.Tags, records[1].Tags)
+		assert.Equal(t, stream.LevelCritical, records[1].Message.Meta.Level())
+		assert.Equal(t, "process 42 (stress) was killed by the kernel OOM killer", records[1].Message.Message)
+	}
+
+	//process in a container
+	records = newKernelParser(boot, func(pid int, memcg string) (uint16, *pm.Command, bool) {
+		return 3, nil, true
+	}).records(oom)
+
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, uint16(3), records[1].Core)
+		assert.Equal(t, KernelCommandID, records[1].Command)
+	}
+
+	//unknown process
+	records = newKernelParser(boot, func(pid int, memcg string) (uint16, *pm.Command, bool) {
+		return 0, nil, false
+	}).records(oom)
+
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, uint16(0), records[1].Core)
+		assert.Equal(t, KernelCommandID, records[1].Command)
+	}
+}
+
+func TestKernelRecordsMemcg(t *testing.T) {
+	boot := time.Unix(1000, 0)
+	parser := newKernelParser(boot, jobOwner)
+
+	//the killed process is already reaped, it's only known by its memory cgroup
+	summary := &kmsgRecord{Priority: 6, Message: "oom-kill:constraint=CONSTRAINT_MEMCG,nodemask=(null),cpuset=/,mems_allowed=0,oom_memcg=/core-3,task_memcg=/core-3,task=stress,pid=999999,uid=0"}
+	oom := &kmsgRecord{Priority: 3, Message: "Memory cgroup out of memory: Killed process 999999 (stress) total-vm:1024kB, anon-rss:512kB"}
+
+	assert.Len(t, parser.records(summary), 1)
+
+	records := parser.records(oom)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, uint16(3), records[1].Core)
+		assert.Equal(t, "process 999999 (stress) was killed by the kernel OOM killer (memory cgroup /core-3)", records[1].Message.Message)
+	}
+
+	//the summary of another process is not used
+	assert.Len(t, parser.records(summary), 1)
+	records = parser.records(&kmsgRecord{Priority: 3, Message: "Out of memory: Killed process 999998 (other)"})
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, uint16(0), records[1].Core)
+	}
+}
+
+func TestJobOwnerMemcg(t *testing.T) {
+	core, cmd, ok := jobOwner(999999, "/core-12")
+	assert.True(t, ok)
+	assert.Equal(t, uint16(12), core)
+	assert.Nil(t, cmd)
+
+	core, _, ok = jobOwner(999999, "/core-12/nested")
+	assert.True(t, ok)
+	assert.Equal(t, uint16(12), core)
+
+	_, _, ok = jobOwner(999999, "/core-12x")
+	assert.False(t, ok)
+}
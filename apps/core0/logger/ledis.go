@@ -3,27 +3,105 @@ package logger
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pborman/uuid"
 	"github.com/zero-os/0-core/base/pm"
 	"github.com/zero-os/0-core/base/pm/stream"
+	"github.com/zero-os/0-core/base/utils"
 	"github.com/zero-os/0-core/apps/core0/transport"
 )
 
 const (
 	MaxRedisQueueSize = 1000
+
+	//DefaultSubscriptionExpire drops subscriptions that are not consumed for that long
+	DefaultSubscriptionExpire = time.Hour
+	//SubscriptionExpireInterval how often idle subscriptions are checked
+	SubscriptionExpireInterval = time.Minute
 )
 
 type levels map[uint16]struct{}
 
+//subscription filters the records pushed to a subscriber queue, a record is pushed
+//only if it matches all the set filters
+type subscription struct {
+	levels levels
+	//core container ID
+	core *uint16
+	//prefix of the command ID
+	prefix string
+	//tags the job must have
+	tags []string
+	//fields filters on the fields of structured messages
+	fields []fieldFilter
+	//expire drops the subscription if it's not renewed or its queue is not consumed for that long
+	expire time.Duration
+	active time.Time
+	//length of the queue right after the last push
+	length int64
+}
+
+//consumed checks if records were popped from the queue since the last push, given its current
+//length. The length must be read after loading the length of the last push, so records pushed
+//in between never count as consumed.
+func (s *subscription) consumed(pushed, length int64) bool {
+	if length >= pushed {
+		return false
+	}
+
+	//a newer push sets the length itself
+	atomic.CompareAndSwapInt64(&s.length, pushed, length)
+	return true
+}
+
+//pushed records the length of the queue after a push, the queue is trimmed to size
+func (s *subscription) pushed(length, size int64) {
+	if length > size {
+		length = size
+	}
+
+	atomic.StoreInt64(&s.length, length)
+}
+
+func (s *subscription) match(record *LogRecord) bool {
+	meta := record.Message.Meta
+	if len(s.levels) > 0 {
+		//only let go messages with requested log level
+		//and all EOF messages.
+		if _, ok := s.levels[meta.Level()]; !ok &&
+			!meta.Is(stream.ExitSuccessFlag|stream.ExitErrorFlag) {
+			return false
+		}
+	}
+
+	if s.core != nil && *s.core != record.Core {
+		return false
+	}
+
+	if !strings.HasPrefix(record.Command, s.prefix) {
+		return false
+	}
+
+	for _, tag := range s.tags {
+		if !utils.InString(record.Tags, tag) {
+			return false
+		}
+	}
+
+	return matchFields(s.fields, record)
+}
+
 // redisLogger send Message to redis queue
 type redisLogger struct {
 	sink     *transport.Sink
 	defaults []uint16
 	size     int64
 	buffer   *stream.Buffer
-	queues   map[string]levels
+	queues   map[string]*subscription
 	m        sync.RWMutex
 
 	ch chan *LogRecord
@@ -40,7 +118,7 @@ func NewLedisLogger(sink *transport.Sink, defaults []uint16, size int64) Logger
 		defaults: defaults,
 		size:     size,
 		buffer:   stream.NewBuffer(MaxStreamRedisQueueSize),
-		queues:   make(map[string]levels),
+		queues:   make(map[string]*subscription),
 		ch:       make(chan *LogRecord, MaxRedisQueueSize),
 	}
 
@@ -48,6 +126,7 @@ func NewLedisLogger(sink *transport.Sink, defaults []uint16, size int64) Logger
 	pm.RegisterBuiltIn("logger.unsubscribe", rl.unSubscribe)
 
 	go rl.pusher()
+	go rl.expirer()
 	return rl
 }
 
@@ -90,24 +169,56 @@ func (l *redisLogger) subscribe(cmd *pm.Command) (interface{}, error) {
 	var args struct {
 		Queue  string   `json:"queue"`
 		Levels []uint16 `json:"levels"`
+		Core   *uint16  `json:"core"`
+		Prefix string   `json:"prefix"`
+		Tags   []string `json:"tags"`
+		Fields []string `json:"fields"`
+		Expire int      `json:"expire"`
 	}
 
 	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
 		return nil, err
 	}
 
+	if args.Expire < 0 {
+		return nil, fmt.Errorf("invalid expire value")
+	}
+
+	fields, err := parseFieldFilters(args.Fields)
+	if err != nil {
+		return nil, err
+	}
+
 	if len(args.Queue) == 0 {
 		args.Queue = uuid.New()
 	}
 
 	args.Queue = fmt.Sprintf("logger:%s", args.Queue)
 
+	lmap := levels{}
+	for _, lvl := range args.Levels {
+		lmap[lvl] = struct{}{}
+	}
+
+	sub := &subscription{
+		levels: lmap,
+		core:   args.Core,
+		prefix: args.Prefix,
+		tags:   args.Tags,
+		fields: fields,
+		expire: time.Duration(args.Expire) * time.Second,
+	}
+
+	if sub.expire == 0 {
+		sub.expire = DefaultSubscriptionExpire
+	}
+
 	go func() {
 		//copying a 100,000 records can take too much,
 		//so we run this in go routine, so the caller
 		//can start reading logs immediately and he doesn't have
 		//to wait until all logs are copied.
-		if err := l.Subscribe(args.Queue, args.Levels); err != nil {
+		if err := l.Subscribe(args.Queue, sub); err != nil {
 			log.Errorf("failed to subscribe to queue: %s", err)
 		}
 	}()
@@ -115,19 +226,20 @@ func (l *redisLogger) subscribe(cmd *pm.Command) (interface{}, error) {
 	return args.Queue, nil
 }
 
-func (l *redisLogger) Subscribe(queue string, lvls []uint16) error {
+//Subscribe starts pushing the records that matches the subscription to the queue. Subscribing
+//again to the same queue updates the filters and keeps the subscription alive.
+func (l *redisLogger) Subscribe(queue string, sub *subscription) error {
 	l.m.Lock()
 	defer l.m.Unlock()
-	if _, ok := l.queues[queue]; ok {
-		return nil
-	}
 
-	lmap := levels{}
-	for _, lvl := range lvls {
-		lmap[lvl] = struct{}{}
+	sub.active = time.Now()
+	if current, ok := l.queues[queue]; ok {
+		sub.length = atomic.LoadInt64(&current.length)
+		l.queues[queue] = sub
+		return nil
 	}
 
-	l.queues[queue] = lmap
+	l.queues[queue] = sub
 
 	//flush backlog
 	for v := l.buffer.Front(); v != nil; v = v.Next() {
@@ -135,14 +247,9 @@ func (l *redisLogger) Subscribe(queue string, lvls []uint16) error {
 		if !ok {
 			return fmt.Errorf("log record in buffer is of wrong type: %v", v.Value)
 		}
-		meta := record.Message.Meta
-		if len(lmap) > 0 {
-			//only let go messages with requested log level
-			//and all EOF messages.
-			if _, ok := lmap[meta.Level()]; !ok &&
-				!meta.Is(stream.ExitSuccessFlag|stream.ExitErrorFlag) {
-				continue
-			}
+
+		if !sub.match(record) {
+			continue
 		}
 
 		bytes, err := json.Marshal(record)
@@ -150,9 +257,12 @@ func (l *redisLogger) Subscribe(queue string, lvls []uint16) error {
 			continue
 		}
 
-		if _, err := l.sink.RPush(queue, bytes); err != nil {
+		length, err := l.sink.RPush(queue, bytes)
+		if err != nil {
 			return err
 		}
+
+		sub.pushed(length, l.size)
 	}
 
 	if err := l.sink.LTrim(queue, -1*l.size, -1); err != nil {
@@ -175,27 +285,85 @@ func (l *redisLogger) pushQueues(record *LogRecord) error {
 		return err
 	}
 
-	meta := record.Message.Meta
-	for queue, lvls := range l.queues {
-		if len(lvls) > 0 {
-			if _, ok := lvls[meta.Level()]; !ok &&
-				!meta.Is(stream.ExitSuccessFlag|stream.ExitErrorFlag) {
-				continue
-			}
+	for queue, sub := range l.queues {
+		if !sub.match(record) {
+			continue
 		}
 
-		if _, err := l.sink.RPush(queue, bytes); err != nil {
+		length, err := l.sink.RPush(queue, bytes)
+		if err != nil {
 			return err
 		}
 
 		if err := l.sink.LTrim(queue, -1*l.size, -1); err != nil {
 			return err
 		}
+
+		sub.pushed(length, l.size)
 	}
 
 	return nil
 }
 
+//expire drops the subscriptions that are idle, a subscription is active as long as it gets
+//renewed, or records are consumed from its queue
+func (l *redisLogger) expire() {
+	//the queues are checked without holding the lock, so the pushes are not blocked on redis
+	l.m.RLock()
+	queues := make(map[string]*subscription, len(l.queues))
+	for queue, sub := range l.queues {
+		queues[queue] = sub
+	}
+	l.m.RUnlock()
+
+	now := time.Now()
+	var idle []string
+	for queue, sub := range queues {
+		pushed := atomic.LoadInt64(&sub.length)
+		length, err := l.sink.LLen(queue)
+		if err != nil {
+			log.Errorf("failed to get logger queue '%s' length: %s", queue, err)
+			continue
+		}
+
+		if sub.consumed(pushed, length) {
+			sub.active = now
+		}
+
+		if now.Sub(sub.active) >= sub.expire {
+			idle = append(idle, queue)
+		}
+	}
+
+	if len(idle) == 0 {
+		return
+	}
+
+	var dropped []string
+	l.m.Lock()
+	for _, queue := range idle {
+		//the queue could have been subscribed to again in the meantime
+		if l.queues[queue] == queues[queue] {
+			delete(l.queues, queue)
+			dropped = append(dropped, queue)
+		}
+	}
+	l.m.Unlock()
+
+	for _, queue := range dropped {
+		log.Infof("dropping idle logger subscription '%s'", queue)
+		if _, err := l.sink.Del(queue); err != nil {
+			log.Errorf("failed to delete logger queue '%s': %s", queue, err)
+		}
+	}
+}
+
+func (l *redisLogger) expirer() {
+	for range time.Tick(SubscriptionExpireInterval) {
+		l.expire()
+	}
+}
+
 func (l *redisLogger) push() error {
 	for {
 		record := <-l.ch
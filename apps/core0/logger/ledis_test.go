@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/pm/stream"
+)
+
+func TestSubscriptionMatch(t *testing.T) {
+	record := func(core uint16, command string, level uint16, tags ...string) *LogRecord {
+		return &LogRecord{
+			Core:    core,
+			Command: command,
+			Tags:    tags,
+			Message: &stream.Message{Meta: stream.NewMeta(level)},
+		}
+	}
+
+	core := uint16(2)
+	sub := subscription{
+		levels: levels{stream.LevelStderr: struct{}{}},
+		core:   &core,
+		prefix: "backup-",
+		tags:   []string{"nightly"},
+	}
+
+	assert.True(t, sub.match(record(2, "backup-1", stream.LevelStderr, "nightly", "db")))
+	assert.False(t, sub.match(record(1, "backup-1", stream.LevelStderr, "nightly")))
+	assert.False(t, sub.match(record(2, "restore-1", stream.LevelStderr, "nightly")))
+	assert.False(t, sub.match(record(2, "backup-1", stream.LevelStderr, "db")))
+	assert.False(t, sub.match(record(2, "backup-1", stream.LevelStdout, "nightly")))
+
+	//exit messages are always pushed regardless of the level
+	exit := record(2, "backup-1", stream.LevelStdout, "nightly")
+	exit.Message.Meta = stream.NewMeta(stream.LevelStdout, stream.ExitSuccessFlag)
+	assert.True(t, sub.match(exit))
+
+	//no filters
+	assert.True(t, (&subscription{}).match(record(0, "any", stream.LevelStdout)))
+}
+
+func TestSubscriptionConsumed(t *testing.T) {
+	var sub subscription
+
+	//nothing pushed nor consumed, an empty queue doesn't keep the subscription alive
+	assert.False(t, sub.consumed(0, 0))
+
+	sub.pushed(3, 10)
+	assert.False(t, sub.consumed(3, 3))
+	//pushed after the length of the last push was loaded
+	assert.False(t, sub.consumed(3, 4))
+
+	assert.True(t, sub.consumed(3, 1))
+	assert.Equal(t, int64(1), sub.length)
+	assert.False(t, sub.consumed(1, 1))
+
+	//the queue is trimmed to its size
+	sub.pushed(12, 10)
+	assert.Equal(t, int64(10), sub.length)
+	assert.False(t, sub.consumed(10, 10))
+}
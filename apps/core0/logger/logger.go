@@ -2,6 +2,7 @@ package logger
 
 import (
 	"github.com/op/go-logging"
+	"github.com/zero-os/0-core/base/pm"
 	"github.com/zero-os/0-core/base/pm/stream"
 )
 
@@ -12,7 +13,10 @@ var (
 type LogRecord struct {
 	Core    uint16          `json:"core"`
 	Command string          `json:"command"`
+	Tags    pm.Tags         `json:"tags,omitempty"`
 	Message *stream.Message `json:"message"`
+	//Fields of structured (level 6) json messages
+	Fields map[string]interface{} `json:"fields,omitempty"`
 }
 
 // Logger interface
@@ -44,5 +48,10 @@ func (logger *ConsoleLogger) LogRecord(record *LogRecord) {
 	if !IsLoggable(logger.defaults, record.Message) {
 		return
 	}
+
+	//the kernel already prints its own logs on the console
+	if record.Command == KernelCommandID {
+		return
+	}
 	log.Infof("[%d]%s %s", record.Core, record.Command, record.Message)
 }
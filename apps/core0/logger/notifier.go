@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/zero-os/0-core/base/pm/stream"
+)
+
+//Queue where the notices are pushed
+type Queue interface {
+	RPush(key string, args ...[]byte) (int64, error)
+	LTrim(key string, start, stop int64) error
+}
+
+//Notifier pushes notices, like alerts and node events, to a capped queue, and logs them as critical
+//messages
+type Notifier struct {
+	queue  Queue
+	key    string
+	size   int64
+	logger Logger
+}
+
+//NewNotifier creates a notifier that keeps the last size notices in the key queue
+func NewNotifier(queue Queue, key string, size int64, logger Logger) *Notifier {
+	return &Notifier{
+		queue:  queue,
+		key:    key,
+		size:   size,
+		logger: logger,
+	}
+}
+
+//Notify pushes the notice to the queue, and logs the message as a critical record of the command
+func (n *Notifier) Notify(command string, message string, notice interface{}) {
+	if data, err := json.Marshal(notice); err != nil {
+		log.Errorf("failed to marshal %s notice: %s", n.key, err)
+	} else if _, err := n.queue.RPush(n.key, data); err != nil {
+		log.Errorf("failed to push %s notice: %s", n.key, err)
+	} else if err := n.queue.LTrim(n.key, -n.size, -1); err != nil {
+		log.Errorf("failed to trim %s queue: %s", n.key, err)
+	}
+
+	n.logger.LogRecord(&LogRecord{
+		Command: command,
+		Message: &stream.Message{
+			Message: message,
+			Epoch:   time.Now().UnixNano(),
+			Meta:    stream.NewMeta(stream.LevelCritical),
+		},
+	})
+}
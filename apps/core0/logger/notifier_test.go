@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/pm/stream"
+)
+
+type testQueue struct {
+	items [][]byte
+	trim  []int64
+}
+
+func (q *testQueue) RPush(key string, args ...[]byte) (int64, error) {
+	q.items = append(q.items, args...)
+	return int64(len(q.items)), nil
+}
+
+func (q *testQueue) LTrim(key string, start, stop int64) error {
+	q.trim = []int64{start, stop}
+	return nil
+}
+
+type testRecords []*LogRecord
+
+func (r *testRecords) LogRecord(record *LogRecord) {
+	*r = append(*r, record)
+}
+
+func TestNotifier(t *testing.T) {
+	var queue testQueue
+	var records testRecords
+
+	notifier := NewNotifier(&queue, "events", 10, &records)
+	notifier.Notify("disk.smart", "disk sda failed", map[string]string{"device": "sda"})
+
+	if assert.Len(t, queue.items, 1) {
+		assert.Equal(t, `{"device":"sda"}`, string(queue.items[0]))
+	}
+	assert.Equal(t, []int64{-10, -1}, queue.trim)
+
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "disk.smart", records[0].Command)
+		assert.Equal(t, "disk sda failed", records[0].Message.Message)
+		assert.Equal(t, stream.LevelCritical, records[0].Message.Meta.Level())
+	}
+}
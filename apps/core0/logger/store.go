@@ -0,0 +1,565 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zero-os/0-core/base/pm"
+)
+
+const (
+	cmdLoggerQuery = "logger.query"
+
+	DefaultLogStoreDir  = "/var/log/core"
+	DefaultLogStoreSize = 100 //MiB
+
+	MinLogSegmentSize = 1024 * 1024
+	LogStoreQueueSize = 1000
+
+	DefaultLogQueryLimit = 100
+	MaxLogQueryLimit     = 1000
+)
+
+//segment is a single log file of the store, with an index of the records it holds so
+//queries only scan the segments that can have matching records.
+type segment struct {
+	ID     uint64              `json:"id"`
+	Size   int64               `json:"size"`
+	First  int64               `json:"first"`
+	Last   int64               `json:"last"`
+	Jobs   map[string]struct{} `json:"jobs"`
+	Cores  map[uint16]struct{} `json:"cores"`
+	Levels uint64              `json:"levels"`
+}
+
+func newSegment(id uint64) *segment {
+	return &segment{
+		ID:    id,
+		Jobs:  make(map[string]struct{}),
+		Cores: make(map[uint16]struct{}),
+	}
+}
+
+func (s *segment) add(record *LogRecord, size int) {
+	s.Size += int64(size)
+	s.Jobs[record.Command] = struct{}{}
+	s.Cores[record.Core] = struct{}{}
+	s.Levels |= 1 << (record.Message.Meta.Level() % 64)
+
+	if epoch := record.Message.Epoch; epoch != 0 {
+		if s.First == 0 || epoch < s.First {
+			s.First = epoch
+		}
+		if epoch > s.Last {
+			s.Last = epoch
+		}
+	}
+}
+
+func (s *segment) match(q *logQuery) bool {
+	if q.Command != "" {
+		if _, ok := s.Jobs[q.Command]; !ok {
+			return false
+		}
+	}
+
+	if q.Core != nil {
+		if _, ok := s.Cores[*q.Core]; !ok {
+			return false
+		}
+	}
+
+	if len(q.Levels) > 0 {
+		var levels uint64
+		for _, level := range q.Levels {
+			levels |= 1 << (level % 64)
+		}
+
+		if s.Levels&levels == 0 {
+			return false
+		}
+	}
+
+	if q.since != 0 && s.Last < q.since {
+		return false
+	}
+
+	if q.until != 0 && (s.First == 0 || s.First > q.until) {
+		return false
+	}
+
+	return true
+}
+
+/*
+storeLogger keeps the logs on disk, so they can be queried with logger.query long after they are
+gone from the in memory backlog. Records are appended to segment files, once a segment is full
+a new one is started, and the oldest segments are removed once the store exceeds its size.
+*/
+type storeLogger struct {
+	dir         string
+	defaults    []uint16
+	size        int64
+	segmentSize int64
+
+	segments []*segment
+	file     *os.File
+	writer   *bufio.Writer
+	//flushed is the size of the current segment that is written to disk
+	flushed int64
+	m       sync.RWMutex
+
+	ch chan *LogRecord
+	//dropped number of records dropped because the queue was full
+	dropped uint64
+}
+
+//NewStoreLogger creates a disk backed logger, size is the max size of the store in MiB
+func NewStoreLogger(dir string, defaults []uint16, size int64) (Logger, error) {
+	if dir == "" {
+		dir = DefaultLogStoreDir
+	}
+
+	if size <= 0 {
+		size = DefaultLogStoreSize
+	}
+
+	size *= 1024 * 1024
+
+	l := &storeLogger{
+		dir:         dir,
+		defaults:    defaults,
+		size:        size,
+		segmentSize: size / 10,
+		ch:          make(chan *LogRecord, LogStoreQueueSize),
+	}
+
+	if l.segmentSize < MinLogSegmentSize {
+		l.segmentSize = MinLogSegmentSize
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+
+	pm.RegisterBuiltIn(cmdLoggerQuery, l.query)
+
+	go l.run()
+	return l, nil
+}
+
+func (l *storeLogger) logPath(id uint64) string {
+	return path.Join(l.dir, fmt.Sprintf("%d.log", id))
+}
+
+func (l *storeLogger) indexPath(id uint64) string {
+	return path.Join(l.dir, fmt.Sprintf("%d.idx", id))
+}
+
+//load loads the index of the existing segments, and opens the last segment for writing
+func (l *storeLogger) load() error {
+	files, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		return err
+	}
+
+	var ids []uint64
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".log") {
+			continue
+		}
+
+		id, err := strconv.ParseUint(strings.TrimSuffix(file.Name(), ".log"), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	for i, id := range ids {
+		var seg *segment
+		if i < len(ids)-1 {
+			//closed segments have their index saved, the last one is always rebuilt since
+			//it was still written to
+			seg, _ = l.loadIndex(id)
+		}
+
+		if seg == nil {
+			if seg, err = l.index(id); err != nil {
+				log.Errorf("failed to index log segment %d: %s", id, err)
+				continue
+			}
+		}
+
+		l.segments = append(l.segments, seg)
+	}
+
+	if len(l.segments) == 0 {
+		l.segments = append(l.segments, newSegment(1))
+	}
+
+	return l.open()
+}
+
+func (l *storeLogger) loadIndex(id uint64) (*segment, error) {
+	data, err := ioutil.ReadFile(l.indexPath(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var seg segment
+	if err := json.Unmarshal(data, &seg); err != nil {
+		return nil, err
+	}
+
+	return &seg, nil
+}
+
+func (l *storeLogger) saveIndex(seg *segment) error {
+	data, err := json.Marshal(seg)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(l.indexPath(seg.ID), data, 0644)
+}
+
+//index builds the index of a segment from its records, a partially written record at the
+//end of the segment is dropped
+func (l *storeLogger) index(id uint64) (*segment, error) {
+	seg := newSegment(id)
+	end, err := l.scan(seg, 0, func(record *LogRecord, offset int64) bool {
+		seg.add(record, 0)
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Truncate(l.logPath(id), end); err != nil {
+		return nil, err
+	}
+
+	seg.Size = end
+	return seg, nil
+}
+
+//open opens the last segment for writing
+func (l *storeLogger) open() error {
+	seg := l.segments[len(l.segments)-1]
+	file, err := os.OpenFile(l.logPath(seg.ID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	l.file = file
+	l.writer = bufio.NewWriter(file)
+	l.flushed = seg.Size
+	return nil
+}
+
+//rotate closes the current segment and starts a new one, the oldest segments are removed
+//if the store is over its size
+func (l *storeLogger) rotate() error {
+	if err := l.writer.Flush(); err != nil {
+		return err
+	}
+
+	l.file.Close()
+
+	current := l.segments[len(l.segments)-1]
+	if err := l.saveIndex(current); err != nil {
+		log.Errorf("failed to save log segment %d index: %s", current.ID, err)
+	}
+
+	l.segments = append(l.segments, newSegment(current.ID+1))
+
+	var total int64
+	for _, seg := range l.segments {
+		total += seg.Size
+	}
+
+	for total > l.size && len(l.segments) > 1 {
+		oldest := l.segments[0]
+		os.Remove(l.logPath(oldest.ID))
+		os.Remove(l.indexPath(oldest.ID))
+		total -= oldest.Size
+		l.segments = l.segments[1:]
+	}
+
+	return l.open()
+}
+
+func (l *storeLogger) LogRecord(record *LogRecord) {
+	if !IsLoggable(l.defaults, record.Message) {
+		return
+	}
+
+	//never block the job that logs on a slow disk
+	select {
+	case l.ch <- record:
+	default:
+		atomic.AddUint64(&l.dropped, 1)
+	}
+}
+
+func (l *storeLogger) write(record *LogRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	data = append(data, '\n')
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.segments[len(l.segments)-1].Size+int64(len(data)) > l.segmentSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := l.writer.Write(data); err != nil {
+		return err
+	}
+
+	l.segments[len(l.segments)-1].add(record, len(data))
+
+	if len(l.ch) == 0 {
+		//flush once there are no more records waiting, so queries see all the logs
+		if err := l.writer.Flush(); err != nil {
+			return err
+		}
+		l.flushed = l.segments[len(l.segments)-1].Size
+	}
+
+	return nil
+}
+
+func (l *storeLogger) run() {
+	for record := range l.ch {
+		if err := l.write(record); err != nil {
+			log.Errorf("failed to store log record: %s", err)
+		}
+
+		if dropped := atomic.SwapUint64(&l.dropped, 0); dropped != 0 {
+			log.Warningf("log store queue is full, dropped %d record(s)", dropped)
+		}
+	}
+}
+
+//scan calls fn with each record of the segment starting from offset, and the offset of the
+//next record, until fn returns false. It returns the offset where the scan stopped.
+func (l *storeLogger) scan(seg *segment, offset int64, fn func(*LogRecord, int64) bool) (int64, error) {
+	file, err := os.Open(l.logPath(seg.ID))
+	if err != nil {
+		return offset, err
+	}
+
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			//a partially written record is ignored
+			return offset, nil
+		} else if err != nil {
+			return offset, err
+		}
+
+		offset += int64(len(line))
+
+		var record LogRecord
+		if err := json.Unmarshal(line, &record); err != nil || record.Message == nil {
+			continue
+		}
+
+		if !fn(&record, offset) {
+			return offset, nil
+		}
+	}
+}
+
+type logQuery struct {
+	Command string   `json:"command"`
+	Core    *uint16  `json:"core"`
+	Levels  []uint16 `json:"levels"`
+	Since   int64    `json:"since"`
+	Until   int64    `json:"until"`
+	Limit   int      `json:"limit"`
+	Cursor  string   `json:"cursor"`
+	Fields  []string `json:"fields"`
+
+	since  int64
+	until  int64
+	fields []fieldFilter
+}
+
+func (q *logQuery) match(record *LogRecord) bool {
+	if q.Command != "" && q.Command != record.Command {
+		return false
+	}
+
+	if q.Core != nil && *q.Core != record.Core {
+		return false
+	}
+
+	if len(q.Levels) > 0 && !record.Message.Meta.Assert(q.Levels...) {
+		return false
+	}
+
+	if q.since != 0 && record.Message.Epoch < q.since {
+		return false
+	}
+
+	if q.until != 0 && record.Message.Epoch > q.until {
+		return false
+	}
+
+	return matchFields(q.fields, record)
+}
+
+//LogPage is a page of logger.query results, Cursor is passed to the next query to get the
+//next page
+type LogPage struct {
+	Records []*LogRecord `json:"records"`
+	Cursor  string       `json:"cursor"`
+	More    bool         `json:"more"`
+}
+
+func parseCursor(cursor string) (uint64, int64, error) {
+	if cursor == "" {
+		return 0, 0, nil
+	}
+
+	parts := strings.SplitN(cursor, ":", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid cursor")
+	}
+
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid cursor")
+	}
+
+	offset, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid cursor")
+	}
+
+	return id, offset, nil
+}
+
+//query returns the stored records that matches the query, oldest first
+func (l *storeLogger) query(cmd *pm.Command) (interface{}, error) {
+	var q logQuery
+	if err := json.Unmarshal(*cmd.Arguments, &q); err != nil {
+		return nil, err
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultLogQueryLimit
+	} else if q.Limit > MaxLogQueryLimit {
+		q.Limit = MaxLogQueryLimit
+	}
+
+	//records epoch is in nano seconds
+	q.since = q.Since * int64(time.Second)
+	q.until = q.Until * int64(time.Second)
+
+	fields, err := parseFieldFilters(q.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	q.fields = fields
+
+	id, offset, err := parseCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	l.m.RLock()
+	segments := make([]*segment, 0, len(l.segments))
+	for _, seg := range l.segments {
+		//segments that are removed since the cursor was returned are skipped
+		if seg.ID >= id && seg.match(&q) {
+			segments = append(segments, seg)
+		}
+	}
+	last := l.segments[len(l.segments)-1].ID
+	flushed := l.flushed
+	l.m.RUnlock()
+
+	page := LogPage{
+		Records: make([]*LogRecord, 0),
+		Cursor:  fmt.Sprintf("%d:%d", last, flushed),
+	}
+
+	for _, seg := range segments {
+		start := int64(0)
+		if seg.ID == id {
+			start = offset
+		}
+
+		end, err := l.scan(seg, start, func(record *LogRecord, next int64) bool {
+			if !q.match(record) {
+				return true
+			}
+
+			page.Records = append(page.Records, record)
+			if len(page.Records) == q.Limit {
+				page.Cursor = fmt.Sprintf("%d:%d", seg.ID, next)
+				page.More = true
+				return false
+			}
+
+			return true
+		})
+
+		if os.IsNotExist(err) {
+			//segment was removed while querying
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if page.More {
+			break
+		}
+
+		if seg.ID == last {
+			//all the records of the current segment up to end are seen
+			page.Cursor = fmt.Sprintf("%d:%d", last, end)
+		}
+	}
+
+	return page, nil
+}
@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/pm"
+	"github.com/zero-os/0-core/base/pm/stream"
+)
+
+func newTestStore(t *testing.T, dir string) *storeLogger {
+	l := &storeLogger{
+		dir:         dir,
+		size:        4 * 1024,
+		segmentSize: 1024,
+		ch:          make(chan *LogRecord, 1),
+	}
+
+	if !assert.NoError(t, l.load()) {
+		t.Fatal()
+	}
+
+	return l
+}
+
+func queryStore(t *testing.T, l *storeLogger, args map[string]interface{}) LogPage {
+	data, _ := json.Marshal(args)
+	raw := json.RawMessage(data)
+
+	page, err := l.query(&pm.Command{Arguments: &raw})
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	return page.(LogPage)
+}
+
+func TestStoreQuery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logstore")
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	defer os.RemoveAll(dir)
+
+	l := newTestStore(t, dir)
+	for i := 0; i < 20; i++ {
+		level := stream.LevelStdout
+		if i%2 == 1 {
+			level = stream.LevelStderr
+		}
+
+		err := l.write(&LogRecord{
+			Core:    uint16(i % 2),
+			Command: fmt.Sprintf("job-%d", i%4),
+			Message: &stream.Message{
+				Message: fmt.Sprintf("message %d", i),
+				Epoch:   int64(i+1) * 1000000000,
+				Meta:    stream.NewMeta(level),
+			},
+		})
+
+		if !assert.NoError(t, err) {
+			t.Fatal()
+		}
+	}
+
+	assert.True(t, len(l.segments) > 1, "store must be rotated")
+
+	page := queryStore(t, l, map[string]interface{}{"command": "job-1"})
+	assert.Len(t, page.Records, 5)
+	assert.False(t, page.More)
+
+	page = queryStore(t, l, map[string]interface{}{"levels": []uint16{stream.LevelStderr}, "core": 1})
+	assert.Len(t, page.Records, 10)
+
+	page = queryStore(t, l, map[string]interface{}{"since": 5, "until": 8})
+	assert.Len(t, page.Records, 4)
+	assert.Equal(t, "message 4", page.Records[0].Message.Message)
+
+	//pagination
+	var messages []string
+	cursor := ""
+	for {
+		page = queryStore(t, l, map[string]interface{}{"limit": 3, "cursor": cursor})
+		for _, record := range page.Records {
+			messages = append(messages, record.Message.Message)
+		}
+
+		cursor = page.Cursor
+		if !page.More {
+			break
+		}
+	}
+
+	assert.Len(t, messages, 20)
+	assert.Equal(t, "message 0", messages[0])
+	assert.Equal(t, "message 19", messages[19])
+
+	//the cursor of the last page points to the end of the store
+	l.write(&LogRecord{Command: "job-new", Message: &stream.Message{Message: "new"}})
+	page = queryStore(t, l, map[string]interface{}{"cursor": cursor})
+	if assert.Len(t, page.Records, 1) {
+		assert.Equal(t, "new", page.Records[0].Message.Message)
+	}
+
+	//reload from disk
+	l.file.Close()
+	l = newTestStore(t, dir)
+	page = queryStore(t, l, map[string]interface{}{"command": "job-1"})
+	assert.Len(t, page.Records, 5)
+}
+
+func TestStoreRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logstore")
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	defer os.RemoveAll(dir)
+
+	l := newTestStore(t, dir)
+	for i := 0; i < 200; i++ {
+		l.write(&LogRecord{
+			Command: "job",
+			Message: &stream.Message{Message: fmt.Sprintf("message %d", i), Meta: stream.NewMeta(stream.LevelStdout)},
+		})
+	}
+
+	var total int64
+	for _, seg := range l.segments {
+		total += seg.Size
+	}
+
+	assert.True(t, total <= l.size+l.segmentSize)
+
+	page := queryStore(t, l, map[string]interface{}{"limit": 1})
+	assert.NotEqual(t, "message 0", page.Records[0].Message.Message)
+}
+
+func TestStoreQueueFull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logstore")
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	defer os.RemoveAll(dir)
+
+	//the store is not running, so nothing consumes the queue
+	l := newTestStore(t, dir)
+	for i := 0; i < 3; i++ {
+		l.LogRecord(&LogRecord{
+			Command: "job",
+			Message: &stream.Message{Message: "message", Meta: stream.NewMeta(stream.LevelStdout)},
+		})
+	}
+
+	assert.Len(t, l.ch, 1)
+	assert.Equal(t, uint64(2), l.dropped)
+}
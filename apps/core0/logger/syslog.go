@@ -0,0 +1,471 @@
+package logger
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zero-os/0-core/base/pm"
+	"github.com/zero-os/0-core/base/pm/stream"
+)
+
+const (
+	cmdSyslogSet = "logger.syslog.set"
+	cmdSyslogGet = "logger.syslog.get"
+
+	DefaultSyslogFacility  = 1 //user-level messages
+	DefaultSyslogSpool     = "/var/cache/core-syslog.spool"
+	DefaultSyslogSpoolSize = 10 //MiB
+
+	SyslogRetryInterval = 10 * time.Second
+	SyslogDialTimeout   = 5 * time.Second
+	SyslogQueueSize     = 1000
+	//MaxSyslogUDPSize messages sent over udp are truncated to this size
+	MaxSyslogUDPSize = 8192
+
+	syslogAppName = "core0"
+	//SD-ID of the structured data, 32473 is the private enterprise number reserved for examples
+	syslogSDID = "zos@32473"
+)
+
+var errSyslogNotConnected = fmt.Errorf("not connected")
+
+//syslog severities
+const (
+	severityCritical = 2
+	severityError    = 3
+	severityWarning  = 4
+	severityNotice   = 5
+	severityInfo     = 6
+	severityDebug    = 7
+)
+
+//severities maps log levels to syslog severities, unlisted levels are informational
+var severities = map[uint16]int{
+	stream.LevelStderr:   severityError,
+	stream.LevelPublic:   severityNotice,
+	stream.LevelWarning:  severityWarning,
+	stream.LevelOpsError: severityError,
+	stream.LevelCritical: severityCritical,
+	stream.LevelStatsd:   severityDebug,
+	stream.LevelDebug:    severityDebug,
+}
+
+//SyslogConfig configures the syslog forwarder
+type SyslogConfig struct {
+	Enabled bool `json:"enabled"`
+	//Address of the syslog server as udp://host:port, tcp://host:port or tls://host:port
+	Address string `json:"address"`
+	//Levels to forward, all levels if not set
+	Levels []uint16 `json:"levels"`
+	//Facility syslog facility code
+	Facility int `json:"facility"`
+	//CA file to verify the server certificate for tls, system CAs are used if not set
+	CA string `json:"ca"`
+	//Spool file where records are kept while the server is not reachable
+	Spool string `json:"spool"`
+	//SpoolSize max size of the spool in MiB, new records are dropped once it's full
+	SpoolSize int64 `json:"spool_size"`
+}
+
+func (c *SyslogConfig) validate() error {
+	if c.Facility == 0 {
+		c.Facility = DefaultSyslogFacility
+	}
+
+	if c.Spool == "" {
+		c.Spool = DefaultSyslogSpool
+	}
+
+	if c.SpoolSize <= 0 {
+		c.SpoolSize = DefaultSyslogSpoolSize
+	}
+
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Facility < 0 || c.Facility > 23 {
+		return fmt.Errorf("invalid syslog facility %d", c.Facility)
+	}
+
+	u, err := url.Parse(c.Address)
+	if err != nil {
+		return err
+	}
+
+	switch u.Scheme {
+	case "udp", "tcp", "tls":
+	default:
+		return fmt.Errorf("invalid syslog address '%s', expecting udp://, tcp:// or tls://", c.Address)
+	}
+
+	if _, _, err := net.SplitHostPort(u.Host); err != nil {
+		return fmt.Errorf("invalid syslog address '%s': %s", c.Address, err)
+	}
+
+	return nil
+}
+
+/*
+syslogLogger forwards log records to a syslog server as RFC 5424 messages, the job ID, container ID
+and level are sent as structured data. Records are spooled on disk while the server is not
+reachable, and sent once the connection is back.
+*/
+type syslogLogger struct {
+	config  SyslogConfig
+	conn    net.Conn
+	retry   time.Time
+	spooled bool
+	dropped int
+	m       sync.Mutex
+
+	ch chan *LogRecord
+	//overflow number of records dropped because the queue was full
+	overflow uint64
+}
+
+//NewSyslogLogger creates a syslog forwarder, it can be reconfigured at runtime with
+//logger.syslog.set
+func NewSyslogLogger(config SyslogConfig) (Logger, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	l := &syslogLogger{
+		config: config,
+		ch:     make(chan *LogRecord, SyslogQueueSize),
+	}
+
+	//records spooled before a restart are sent first
+	if _, err := os.Stat(config.Spool); err == nil {
+		l.spooled = true
+	}
+
+	pm.RegisterBuiltIn(cmdSyslogSet, l.set)
+	pm.RegisterBuiltIn(cmdSyslogGet, l.get)
+
+	go l.run()
+	return l, nil
+}
+
+func (l *syslogLogger) LogRecord(record *LogRecord) {
+	l.m.Lock()
+	enabled, levels := l.config.Enabled, l.config.Levels
+	l.m.Unlock()
+
+	if !enabled || !IsLoggable(levels, record.Message) {
+		return
+	}
+
+	//never block the job that logs on a slow syslog server
+	select {
+	case l.ch <- record:
+	default:
+		atomic.AddUint64(&l.overflow, 1)
+	}
+}
+
+func (l *syslogLogger) run() {
+	ticker := time.NewTicker(SyslogRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case record := <-l.ch:
+			l.forward(record)
+
+			if overflow := atomic.SwapUint64(&l.overflow, 0); overflow != 0 {
+				log.Warningf("syslog queue is full, dropped %d record(s)", overflow)
+			}
+		case <-ticker.C:
+			l.m.Lock()
+			if l.config.Enabled {
+				l.flush()
+			}
+			l.m.Unlock()
+		}
+	}
+}
+
+func (l *syslogLogger) forward(record *LogRecord) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if !l.config.Enabled {
+		return
+	}
+
+	msg := l.format(record)
+
+	//keep the order, nothing is sent before the spooled records
+	if !l.flush() {
+		l.spool(msg)
+		return
+	}
+
+	if err := l.send(msg); err != nil {
+		if err != errSyslogNotConnected {
+			log.Errorf("failed to forward log to syslog: %s", err)
+			l.disconnect()
+		}
+		l.spool(msg)
+	}
+}
+
+func (l *syslogLogger) format(record *LogRecord) []byte {
+	level := record.Message.Meta.Level()
+	severity, ok := severities[level]
+	if !ok {
+		severity = severityInfo
+	}
+
+	ts := time.Now()
+	if record.Message.Epoch != 0 {
+		ts = time.Unix(0, record.Message.Epoch)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s - - [%s core=\"%d\" job=\"%s\" level=\"%d\"] %s",
+		l.config.Facility*8+severity,
+		ts.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		hostname,
+		syslogAppName,
+		syslogSDID,
+		record.Core,
+		sdEscape(record.Command),
+		level,
+		record.Message.Message,
+	)
+
+	return buf.Bytes()
+}
+
+//sdEscape escapes a structured data param value
+func sdEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+func (l *syslogLogger) dial() (net.Conn, error) {
+	u, err := url.Parse(l.config.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: SyslogDialTimeout}
+	if u.Scheme != "tls" {
+		return dialer.Dial(u.Scheme, u.Host)
+	}
+
+	config := &tls.Config{}
+	if l.config.CA != "" {
+		data, err := ioutil.ReadFile(l.config.CA)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no valid certificates found in '%s'", l.config.CA)
+		}
+	}
+
+	return tls.DialWithDialer(dialer, "tcp", u.Host, config)
+}
+
+//connect makes sure the connection is open, failed connections are only retried every
+//SyslogRetryInterval
+func (l *syslogLogger) connect() bool {
+	if l.conn != nil {
+		return true
+	}
+
+	if time.Now().Before(l.retry) {
+		return false
+	}
+
+	conn, err := l.dial()
+	if err != nil {
+		log.Errorf("failed to connect to syslog server '%s': %s", l.config.Address, err)
+		l.retry = time.Now().Add(SyslogRetryInterval)
+		return false
+	}
+
+	l.conn = conn
+	return true
+}
+
+func (l *syslogLogger) disconnect() {
+	if l.conn != nil {
+		l.conn.Close()
+		l.conn = nil
+	}
+
+	l.retry = time.Now().Add(SyslogRetryInterval)
+}
+
+//send writes a single message, stream connections use octet counting framing (RFC 6587)
+func (l *syslogLogger) send(msg []byte) error {
+	if !l.connect() {
+		return errSyslogNotConnected
+	}
+
+	if _, ok := l.conn.(*net.UDPConn); ok {
+		if len(msg) > MaxSyslogUDPSize {
+			msg = msg[:MaxSyslogUDPSize]
+		}
+	} else {
+		msg = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+	}
+
+	l.conn.SetWriteDeadline(time.Now().Add(SyslogDialTimeout))
+	_, err := l.conn.Write(msg)
+	return err
+}
+
+//spool keeps a message on disk until the server is reachable, messages are framed
+//with their length like on the wire
+func (l *syslogLogger) spool(msg []byte) {
+	if err := os.MkdirAll(path.Dir(l.config.Spool), 0755); err != nil {
+		log.Errorf("failed to create syslog spool: %s", err)
+		return
+	}
+
+	f, err := os.OpenFile(l.config.Spool, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		log.Errorf("failed to open syslog spool: %s", err)
+		return
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return
+	}
+
+	data := append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+	if info.Size()+int64(len(data)) > l.config.SpoolSize*1024*1024 {
+		if l.dropped == 0 {
+			log.Warningf("syslog spool is full, dropping logs")
+		}
+		l.dropped++
+		return
+	}
+
+	if _, err := f.Write(data); err != nil {
+		log.Errorf("failed to write syslog spool: %s", err)
+		return
+	}
+
+	l.spooled = true
+}
+
+//flush sends the spooled messages, it returns true if the spool is empty. The spool is only
+//read once connected, so records logged while the server is down are just appended to it.
+func (l *syslogLogger) flush() bool {
+	if !l.spooled {
+		return true
+	}
+
+	if !l.connect() {
+		return false
+	}
+
+	data, err := ioutil.ReadFile(l.config.Spool)
+	if os.IsNotExist(err) || (err == nil && len(data) == 0) {
+		l.spooled = false
+		return true
+	} else if err != nil {
+		log.Errorf("failed to read syslog spool: %s", err)
+		return false
+	}
+
+	sent := 0
+	for sent < len(data) {
+		space := bytes.IndexByte(data[sent:], ' ')
+		if space < 0 {
+			break
+		}
+
+		size, err := strconv.Atoi(string(data[sent : sent+space]))
+		start := sent + space + 1
+		if err != nil || start+size > len(data) {
+			log.Errorf("corrupted syslog spool, dropping remaining records")
+			sent = len(data)
+			break
+		}
+
+		if err := l.send(data[start : start+size]); err != nil {
+			log.Errorf("failed to forward spooled logs to syslog: %s", err)
+			l.disconnect()
+			break
+		}
+
+		sent = start + size
+	}
+
+	if sent == len(data) {
+		if l.dropped > 0 {
+			log.Warningf("%d logs were dropped while syslog server was not reachable", l.dropped)
+			l.dropped = 0
+		}
+		if err := os.Remove(l.config.Spool); err != nil {
+			log.Errorf("failed to remove syslog spool: %s", err)
+			return false
+		}
+
+		l.spooled = false
+		return true
+	}
+
+	if err := ioutil.WriteFile(l.config.Spool, data[sent:], 0600); err != nil {
+		log.Errorf("failed to update syslog spool: %s", err)
+	}
+
+	return false
+}
+
+func (l *syslogLogger) set(cmd *pm.Command) (interface{}, error) {
+	var config SyslogConfig
+	if err := json.Unmarshal(*cmd.Arguments, &config); err != nil {
+		return nil, err
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.disconnect()
+	l.retry = time.Time{}
+	l.config = config
+
+	return nil, nil
+}
+
+func (l *syslogLogger) get(cmd *pm.Command) (interface{}, error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	return l.config, nil
+}
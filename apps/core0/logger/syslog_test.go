@@ -0,0 +1,134 @@
+package logger
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/pm/stream"
+)
+
+//readFrame reads a single octet counted syslog message
+func readFrame(t *testing.T, reader *bufio.Reader) string {
+	var size int
+	if _, err := fmt.Fscanf(reader, "%d ", &size); !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	msg := make([]byte, size)
+	if _, err := reader.Read(msg); !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	return string(msg)
+}
+
+func TestSyslogFormat(t *testing.T) {
+	l := &syslogLogger{config: SyslogConfig{Facility: 16}}
+
+	msg := string(l.format(&LogRecord{
+		Core:    2,
+		Command: `job"1`,
+		Message: &stream.Message{
+			Message: "disk failed",
+			Epoch:   time.Date(2018, 1, 2, 3, 4, 5, 6000, time.UTC).UnixNano(),
+			Meta:    stream.NewMeta(stream.LevelCritical),
+		},
+	}))
+
+	hostname, _ := os.Hostname()
+	assert.Equal(t, fmt.Sprintf(`<130>1 2018-01-02T03:04:05.000006Z %s core0 - - [zos@32473 core="2" job="job\"1" level="9"] disk failed`, hostname), msg)
+}
+
+func TestSyslogSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syslog")
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	defer os.RemoveAll(dir)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	config := SyslogConfig{
+		Enabled: true,
+		Address: fmt.Sprintf("tcp://%s", listener.Addr()),
+		Spool:   path.Join(dir, "spool"),
+	}
+
+	if !assert.NoError(t, config.validate()) {
+		t.Fatal()
+	}
+
+	l := &syslogLogger{config: config}
+	record := func(msg string) *LogRecord {
+		return &LogRecord{Command: "job", Message: &stream.Message{Message: msg, Meta: stream.NewMeta(stream.LevelStdout)}}
+	}
+
+	//server is not accepting yet, but connection succeeds
+	l.forward(record("first"))
+	conn, err := listener.Accept()
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	reader := bufio.NewReader(conn)
+	assert.True(t, strings.HasSuffix(readFrame(t, reader), "first"))
+
+	//server is down, records are spooled
+	conn.Close()
+	listener.Close()
+	l.disconnect()
+	l.retry = time.Time{}
+
+	l.forward(record("second"))
+	l.forward(record("third"))
+	assert.True(t, l.spooled)
+
+	//server is back, spooled records are sent in order
+	listener, err = net.Listen("tcp", strings.TrimPrefix(config.Address, "tcp://"))
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+	defer listener.Close()
+
+	l.retry = time.Time{}
+	l.forward(record("fourth"))
+	assert.False(t, l.spooled)
+
+	conn, err = listener.Accept()
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+	defer conn.Close()
+
+	reader = bufio.NewReader(conn)
+	for _, expected := range []string{"second", "third", "fourth"} {
+		assert.True(t, strings.HasSuffix(readFrame(t, reader), expected))
+	}
+}
+
+func TestSyslogQueueFull(t *testing.T) {
+	l := &syslogLogger{
+		config: SyslogConfig{Enabled: true},
+		ch:     make(chan *LogRecord, 1),
+	}
+
+	//the forwarder is not running, so nothing consumes the queue
+	for i := 0; i < 3; i++ {
+		l.LogRecord(&LogRecord{Command: "job", Message: &stream.Message{Message: "message", Meta: stream.NewMeta(stream.LevelStdout)}})
+	}
+
+	assert.Len(t, l.ch, 1)
+	assert.Equal(t, uint64(2), l.overflow)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/op/go-logging"
 	"github.com/zero-os/0-core/apps/core0/assets"
+	"github.com/zero-os/0-core/apps/core0/audit"
 	"github.com/zero-os/0-core/apps/core0/bootstrap"
 	"github.com/zero-os/0-core/apps/core0/logger"
 	"github.com/zero-os/0-core/apps/core0/options"
@@ -15,13 +16,15 @@ import (
 	"github.com/zero-os/0-core/apps/core0/subsys/containers"
 	"github.com/zero-os/0-core/apps/core0/subsys/kvm"
 	"github.com/zero-os/0-core/base"
+	"github.com/zero-os/0-core/base/auth"
 	"github.com/zero-os/0-core/base/pm"
 	"github.com/zero-os/0-core/base/settings"
 
 	"os/signal"
 	"syscall"
+	"time"
 
-	_ "github.com/zero-os/0-core/apps/core0/builtin"
+	"github.com/zero-os/0-core/apps/core0/builtin"
 	_ "github.com/zero-os/0-core/apps/core0/builtin/btrfs"
 	"github.com/zero-os/0-core/apps/core0/transport"
 	_ "github.com/zero-os/0-core/base/builtin"
@@ -81,6 +84,16 @@ func Splash() {
 	screen.Refresh()
 }
 
+//organization gets the IYO organization from the kernel cmdline, if not set no authentication
+//is required.
+func organization() string {
+	if orgs, ok := options.Options.Kernel.Get("organization"); ok {
+		return orgs[len(orgs)-1]
+	}
+
+	return ""
+}
+
 type console struct{}
 
 func (*console) Result(cmd *pm.Command, result *pm.JobResult) {
@@ -137,13 +150,35 @@ func main() {
 
 	//configure logging handlers from configurations
 	log.Infof("Configure logging")
-	cfg := transport.SinkConfig{Port: 6379}
+	cfg := transport.SinkConfig{
+		Port:        6379,
+		Workers:     config.Sink.Workers,
+		Queues:      config.Sink.Queue,
+		ResultTTL:   config.Sink.ResultTTL,
+		ResultStore: config.Sink.ResultStore,
+	}
 	sink, err := transport.NewSink(cfg)
 	if err != nil {
 		log.Errorf("failed to start command sink: %s", err)
 	}
 
+	if config.Audit.Enabled {
+		auditor, err := audit.New(config.Audit.File)
+		if err != nil {
+			log.Errorf("failed to start audit log: %s", err)
+		} else {
+			pm.AddHandle(auditor)
+		}
+	}
+
 	logger.ConfigureLogging(sink)
+	builtin.SetEvents(sink, logger.Current)
+
+	if !options.Agent() {
+		if err := logger.KernelLogs(); err != nil {
+			log.Errorf("failed to read kernel logs: %s", err)
+		}
+	}
 
 	bs := bootstrap.NewBootstrap(options.Agent())
 	bs.First()
@@ -188,11 +223,117 @@ func main() {
 	log.Infof("Starting Sinks")
 
 	sink.Start()
+
+	if config.API.Enabled {
+		api, err := transport.NewAPI(sink, transport.APIConfig{
+			Listen: config.API.Listen,
+			Cert:   config.API.Cert,
+			Key:    config.API.Key,
+			Auth: auth.Options{
+				Organization: organization(),
+				Tokens:       config.API.Tokens,
+				JWTKey:       config.API.JWTKey,
+				JWTClaim:     config.API.JWTClaim,
+				ClientCA:     config.API.ClientCA,
+				Policy:       config.API.Policy,
+			},
+		})
+
+		if err != nil {
+			log.Errorf("failed to start API: %s", err)
+		} else {
+			api.Start()
+		}
+	}
 	screen.Refresh()
 
 	if config.Stats.Enabled {
+		if len(config.Stats.Period) != 0 {
+			var periods []stats.Period
+			for _, period := range config.Stats.Period {
+				periods = append(periods, stats.Period{Duration: period.Duration, History: period.History})
+			}
+
+			if err := stats.SetPeriods(periods); err != nil {
+				log.Errorf("invalid stats periods, using default periods: %s", err)
+			}
+		}
+
+		if config.Stats.Expire > 0 {
+			stats.Expire = time.Duration(config.Stats.Expire) * time.Second
+		}
+
+		if snapshot := config.Stats.Snapshot; snapshot.Enabled {
+			stats.SnapshotFile = snapshot.File
+			if stats.SnapshotFile == "" {
+				stats.SnapshotFile = stats.DefaultSnapshotFile
+			}
+
+			if snapshot.Interval > 0 {
+				stats.SnapshotInterval = time.Duration(snapshot.Interval) * time.Second
+			}
+		}
+
 		aggregator := stats.NewLedisStatsAggregator(sink)
 		pm.AddHandle(aggregator)
+
+		alerter := stats.NewAlerter(sink, logger.Current)
+		for name, rule := range config.Alert {
+			err := alerter.Set(stats.Rule{
+				Name:       name,
+				Key:        rule.Key,
+				Tags:       rule.Tags,
+				Period:     rule.Period,
+				Field:      rule.Field,
+				Comparison: rule.Comparison,
+				Threshold:  rule.Threshold,
+				Duration:   rule.Duration,
+			})
+
+			if err != nil {
+				log.Errorf("invalid alert rule: %s", err)
+			}
+		}
+		aggregator.Observe(alerter)
+
+		if export := config.Stats.Export; export.Enabled {
+			exporter, err := stats.NewExporter(stats.ExportConfig{
+				Target:     export.Target,
+				Interval:   time.Duration(export.Interval) * time.Second,
+				Buffer:     export.Buffer,
+				BufferSize: export.BufferSize,
+				Tags:       export.Tags,
+			})
+
+			if err != nil {
+				log.Errorf("failed to start stats export: %s", err)
+			} else {
+				pm.AddHandle(exporter)
+				exporter.Start()
+			}
+		}
+
+		if prom := config.Stats.Prometheus; prom.Enabled {
+			endpoint, err := stats.NewPrometheus(aggregator, stats.PrometheusConfig{
+				Listen: prom.Listen,
+				Cert:   prom.Cert,
+				Key:    prom.Key,
+				Auth: auth.Options{
+					Organization: organization(),
+					Tokens:       prom.Tokens,
+					JWTKey:       prom.JWTKey,
+					JWTClaim:     prom.JWTClaim,
+					ClientCA:     prom.ClientCA,
+					Policy:       prom.Policy,
+				},
+			})
+
+			if err != nil {
+				log.Errorf("failed to start metrics endpoint: %s", err)
+			} else {
+				endpoint.Start()
+			}
+		}
 	}
 
 	select {}
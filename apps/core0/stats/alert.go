@@ -0,0 +1,390 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zero-os/0-core/apps/core0/logger"
+	"github.com/zero-os/0-core/base/pm"
+)
+
+const (
+	//AlertQueueKey redis queue where the firing and resolved alerts are pushed
+	AlertQueueKey = "alerts"
+	//AlertCommandID is the command ID of the alerts log records
+	AlertCommandID = "alert"
+	//AlertQueueSize max number of alerts kept in the queue
+	AlertQueueSize = 1000
+	//AlertStaleInterval between 2 checks of the alerts of metrics that stopped reporting
+	AlertStaleInterval = time.Minute
+
+	AlertPending  = "pending"
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+
+	cmdAlertSet    = "alert.set"
+	cmdAlertDelete = "alert.delete"
+	cmdAlertList   = "alert.list"
+)
+
+//comparisons supported by the alert rules
+var comparisons = map[string]func(a, b float64) bool{
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+//Rule is an alerting rule, it fires when the field of the samples of a metric matches the comparison
+//with the threshold for at least duration seconds, and is resolved on the first sample that doesn't.
+type Rule struct {
+	Name string `json:"name"`
+	//Key of the metric
+	Key string `json:"key"`
+	//Tags the metric must have, including the id
+	Tags map[string]string `json:"tags,omitempty"`
+	//Period of the evaluated samples, the shortest period if not set
+	Period int64 `json:"period"`
+	//Field of the sample to compare (avg, max, last, total, count or a percentile like p99), avg if not set
+	Field string `json:"field"`
+	//Comparison operator (>, >=, <, <=, ==, !=)
+	Comparison string  `json:"comparison"`
+	Threshold  float64 `json:"threshold"`
+	//Duration in seconds the condition must hold before the alert fires
+	Duration int64 `json:"duration"`
+}
+
+func (r *Rule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule name is required")
+	}
+
+	if r.Key == "" {
+		return fmt.Errorf("rule %s: metric key is required", r.Name)
+	}
+
+	if _, ok := comparisons[r.Comparison]; !ok {
+		return fmt.Errorf("rule %s: invalid comparison '%s'", r.Name, r.Comparison)
+	}
+
+	if r.Field == "" {
+		r.Field = "avg"
+	}
+
+	if r.Period == 0 && len(Periods) != 0 {
+		r.Period = Periods[0].Duration
+	}
+
+	for _, period := range Periods {
+		if period.Duration == r.Period {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("rule %s: unknown period %d", r.Name, r.Period)
+}
+
+func (r *Rule) match(key string, tags []pm.Tag, period int64) bool {
+	if r.Key != key || r.Period != period {
+		return false
+	}
+
+	for k, v := range r.Tags {
+		found := false
+		for _, tag := range tags {
+			if tag.Key == k && tag.Value == v {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+//value gets the rule field of the sample
+func (r *Rule) value(sample *Sample) (float64, bool) {
+	switch r.Field {
+	case "avg":
+		return sample.Avg, true
+	case "max":
+		return sample.Max, true
+	case "last":
+		return sample.Last, true
+	case "total":
+		return sample.Total, true
+	case "count":
+		return float64(sample.Count), true
+	}
+
+	v, ok := sample.Percentiles[r.Field]
+	return v, ok
+}
+
+//Alert is the state of a rule for a metric
+type Alert struct {
+	Rule  string            `json:"rule"`
+	Key   string            `json:"key"`
+	Tags  map[string]string `json:"tags,omitempty"`
+	State string            `json:"state"`
+	//Value of the last evaluated sample
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	//Since start of the first sample that matched the rule
+	Since int64 `json:"since"`
+	//Time end of the last evaluated sample
+	Time int64 `json:"time"`
+	//Stale is set when the alert is resolved because the metric stopped reporting
+	Stale bool `json:"stale,omitempty"`
+}
+
+func (a *Alert) String() string {
+	var tags []pm.Tag
+	for k, v := range a.Tags {
+		tags = append(tags, pm.Tag{Key: k, Value: v})
+	}
+
+	state := a.State
+	if a.Stale {
+		state += " (stale)"
+	}
+
+	return fmt.Sprintf("alert %s %s: %s%s = %v (threshold %v) since %s",
+		a.Rule, state, a.Key, labels(tags), a.Value, a.Threshold, time.Unix(a.Since, 0).UTC().Format(time.RFC3339))
+}
+
+//Observer is notified of the complete samples of the aggregated metrics
+type Observer interface {
+	Sample(key string, tags []pm.Tag, period int64, sample *Sample)
+}
+
+/*
+Alerter evaluates the alerting rules on each complete sample of the aggregated metrics. Firing and
+resolved alerts are pushed to the alerts queue, and logged as critical messages. Alerts of metrics that
+stopped reporting are resolved as stale.
+*/
+type Alerter struct {
+	rules    map[string]*Rule
+	alerts   map[string]map[string]*Alert //rule -> metric -> alert
+	notifier *logger.Notifier
+	m        sync.Mutex
+}
+
+func newAlerter(queue logger.Queue, records logger.Logger) *Alerter {
+	return &Alerter{
+		rules:    make(map[string]*Rule),
+		alerts:   make(map[string]map[string]*Alert),
+		notifier: logger.NewNotifier(queue, AlertQueueKey, AlertQueueSize, records),
+	}
+}
+
+//NewAlerter creates the alerting engine, rules are managed with alert.set, alert.delete and alert.list
+func NewAlerter(queue logger.Queue, records logger.Logger) *Alerter {
+	a := newAlerter(queue, records)
+
+	pm.RegisterBuiltIn(cmdAlertSet, a.set)
+	pm.RegisterBuiltIn(cmdAlertDelete, a.delete)
+	pm.RegisterBuiltIn(cmdAlertList, a.list)
+
+	go a.stale(AlertStaleInterval)
+
+	return a
+}
+
+//Set adds a rule, or replaces the rule with the same name
+func (a *Alerter) Set(rule Rule) error {
+	if err := rule.validate(); err != nil {
+		return err
+	}
+
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	a.rules[rule.Name] = &rule
+	delete(a.alerts, rule.Name)
+	return nil
+}
+
+//Delete a rule, its alerts are dropped
+func (a *Alerter) Delete(name string) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	if _, ok := a.rules[name]; !ok {
+		return fmt.Errorf("rule %s not found", name)
+	}
+
+	delete(a.rules, name)
+	delete(a.alerts, name)
+	return nil
+}
+
+//Sample implements Observer
+func (a *Alerter) Sample(key string, tags []pm.Tag, period int64, sample *Sample) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	for _, rule := range a.rules {
+		if !rule.match(key, tags, period) {
+			continue
+		}
+
+		if value, ok := rule.value(sample); ok {
+			a.evaluate(rule, key, tags, sample.Start, sample.Start+period, value)
+		}
+	}
+}
+
+func (a *Alerter) evaluate(rule *Rule, key string, tags []pm.Tag, start, end int64, value float64) {
+	alerts, ok := a.alerts[rule.Name]
+	if !ok {
+		alerts = make(map[string]*Alert)
+		a.alerts[rule.Name] = alerts
+	}
+
+	metric := key + labels(tags)
+	alert, ok := alerts[metric]
+
+	if !comparisons[rule.Comparison](value, rule.Threshold) {
+		if !ok {
+			return
+		}
+
+		delete(alerts, metric)
+		if alert.State == AlertFiring {
+			alert.State = AlertResolved
+			alert.Value = value
+			alert.Time = end
+			a.notify(alert)
+		}
+
+		return
+	}
+
+	if !ok {
+		alert = &Alert{
+			Rule:      rule.Name,
+			Key:       key,
+			Tags:      make(map[string]string),
+			State:     AlertPending,
+			Threshold: rule.Threshold,
+			Since:     start,
+		}
+
+		for _, tag := range tags {
+			alert.Tags[tag.Key] = tag.Value
+		}
+
+		alerts[metric] = alert
+	}
+
+	alert.Value = value
+	alert.Time = end
+
+	if alert.State == AlertPending && end-alert.Since >= rule.Duration {
+		alert.State = AlertFiring
+		a.notify(alert)
+	}
+}
+
+/*
+expire resolves the firing alerts of the metrics that didn't complete a sample for a period plus Expire
+(the metric is not tracked anymore), pending alerts are dropped.
+*/
+func (a *Alerter) expire(now int64) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	for name, alerts := range a.alerts {
+		rule := a.rules[name]
+		for metric, alert := range alerts {
+			if now-alert.Time <= rule.Period+int64(Expire/time.Second) {
+				continue
+			}
+
+			delete(alerts, metric)
+			if alert.State == AlertFiring {
+				alert.State = AlertResolved
+				alert.Stale = true
+				alert.Time = now
+				a.notify(alert)
+			}
+		}
+	}
+}
+
+//stale checks the alerts of the metrics that stopped reporting periodically
+func (a *Alerter) stale(interval time.Duration) {
+	for range time.Tick(interval) {
+		a.expire(time.Now().Unix())
+	}
+}
+
+func (a *Alerter) notify(alert *Alert) {
+	a.notifier.Notify(AlertCommandID, alert.String(), alert)
+}
+
+//alert.set
+func (a *Alerter) set(cmd *pm.Command) (interface{}, error) {
+	var rule Rule
+	if err := json.Unmarshal(*cmd.Arguments, &rule); err != nil {
+		return nil, err
+	}
+
+	return nil, a.Set(rule)
+}
+
+//alert.delete
+func (a *Alerter) delete(cmd *pm.Command) (interface{}, error) {
+	var args struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
+		return nil, err
+	}
+
+	return nil, a.Delete(args.Name)
+}
+
+//RuleState is a rule with its pending and firing alerts
+type RuleState struct {
+	Rule   *Rule   `json:"rule"`
+	Alerts []Alert `json:"alerts"`
+}
+
+//List the rules and their alerts
+func (a *Alerter) List() map[string]RuleState {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	result := make(map[string]RuleState)
+	for name, rule := range a.rules {
+		state := RuleState{Rule: rule, Alerts: []Alert{}}
+		for _, alert := range a.alerts[name] {
+			state.Alerts = append(state.Alerts, *alert)
+		}
+
+		sort.Slice(state.Alerts, func(i, j int) bool {
+			return state.Alerts[i].Since < state.Alerts[j].Since
+		})
+
+		result[name] = state
+	}
+
+	return result
+}
+
+//alert.list
+func (a *Alerter) list(cmd *pm.Command) (interface{}, error) {
+	return a.List(), nil
+}
@@ -0,0 +1,138 @@
+package stats
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/apps/core0/logger"
+	"github.com/zero-os/0-core/base/pm"
+	"github.com/zero-os/0-core/base/pm/stream"
+)
+
+type testQueue struct {
+	alerts []Alert
+}
+
+func (q *testQueue) RPush(key string, args ...[]byte) (int64, error) {
+	for _, data := range args {
+		var alert Alert
+		if err := json.Unmarshal(data, &alert); err != nil {
+			return 0, err
+		}
+
+		q.alerts = append(q.alerts, alert)
+	}
+
+	return int64(len(q.alerts)), nil
+}
+
+func (q *testQueue) LTrim(key string, start, stop int64) error {
+	return nil
+}
+
+type testLogger struct {
+	records []*logger.LogRecord
+}
+
+func (l *testLogger) LogRecord(record *logger.LogRecord) {
+	l.records = append(l.records, record)
+}
+
+func TestRuleValidate(t *testing.T) {
+	rule := Rule{Name: "cpu", Key: "machine.CPU.percent", Comparison: ">"}
+	if assert.NoError(t, rule.validate()) {
+		assert.Equal(t, "avg", rule.Field)
+		assert.Equal(t, Periods[0].Duration, rule.Period)
+	}
+
+	assert.Error(t, (&Rule{Name: "cpu", Key: "machine.CPU.percent", Comparison: "=>"}).validate())
+	assert.Error(t, (&Rule{Name: "cpu", Key: "machine.CPU.percent", Comparison: ">", Period: 7}).validate())
+	assert.Error(t, (&Rule{Name: "cpu", Comparison: ">"}).validate())
+}
+
+func TestAlerter(t *testing.T) {
+	var queue testQueue
+	var log testLogger
+	alerter := newAlerter(&queue, &log)
+
+	err := alerter.Set(Rule{
+		Name:       "cpu",
+		Key:        "machine.CPU.percent",
+		Tags:       map[string]string{"type": "phys"},
+		Period:     300,
+		Comparison: ">",
+		Threshold:  90,
+		Duration:   600,
+	})
+
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	tags := []pm.Tag{{Key: "type", Value: "phys"}, {Key: IDTag, Value: "0"}}
+	feed := func(start int64, avg float64) {
+		alerter.Sample("machine.CPU.percent", tags, 300, &Sample{Avg: avg, Count: 1, Start: start})
+	}
+
+	feed(300, 95)
+	//not matching the rule period or tags
+	alerter.Sample("machine.CPU.percent", tags, 3600, &Sample{Avg: 95, Count: 1, Start: 0})
+	alerter.Sample("machine.CPU.percent", []pm.Tag{{Key: "type", Value: "virt"}}, 300, &Sample{Avg: 95, Count: 1, Start: 300})
+
+	rules := alerter.List()
+	if assert.Len(t, rules["cpu"].Alerts, 1) {
+		assert.Equal(t, AlertPending, rules["cpu"].Alerts[0].State)
+	}
+	assert.Len(t, queue.alerts, 0)
+
+	feed(600, 96)
+	if assert.Len(t, queue.alerts, 1) {
+		alert := queue.alerts[0]
+		assert.Equal(t, AlertFiring, alert.State)
+		assert.Equal(t, int64(300), alert.Since)
+		assert.Equal(t, 96., alert.Value)
+		assert.Equal(t, map[string]string{"type": "phys", "id": "0"}, alert.Tags)
+	}
+
+	feed(900, 97)
+	assert.Len(t, queue.alerts, 1)
+
+	feed(1200, 50)
+	if assert.Len(t, queue.alerts, 2) {
+		assert.Equal(t, AlertResolved, queue.alerts[1].State)
+	}
+	assert.Len(t, alerter.List()["cpu"].Alerts, 0)
+
+	if assert.Len(t, log.records, 2) {
+		assert.Equal(t, AlertCommandID, log.records[0].Command)
+		assert.Equal(t, stream.LevelCritical, log.records[0].Message.Meta.Level())
+	}
+
+	//a short breach never fires
+	feed(1500, 99)
+	feed(1800, 10)
+	assert.Len(t, queue.alerts, 2)
+
+	//alerts of metrics that stopped reporting are resolved as stale
+	feed(2100, 99)
+	feed(2400, 99)
+	if assert.Len(t, queue.alerts, 3) {
+		assert.Equal(t, AlertFiring, queue.alerts[2].State)
+	}
+
+	alerter.expire(2700 + 300 + int64(Expire/time.Second))
+	assert.Len(t, queue.alerts, 3)
+
+	alerter.expire(2701 + 300 + int64(Expire/time.Second))
+	if assert.Len(t, queue.alerts, 4) {
+		assert.Equal(t, AlertResolved, queue.alerts[3].State)
+		assert.True(t, queue.alerts[3].Stale)
+	}
+	assert.Len(t, alerter.List()["cpu"].Alerts, 0)
+
+	assert.NoError(t, alerter.Delete("cpu"))
+	assert.Len(t, alerter.List(), 0)
+	assert.Error(t, alerter.Delete("cpu"))
+}
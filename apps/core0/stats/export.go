@@ -0,0 +1,439 @@
+package stats
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zero-os/0-core/base/pm"
+)
+
+const (
+	DefaultExportInterval   = 10 * time.Second
+	DefaultExportBuffer     = "/var/cache/core-stats-export.buffer"
+	DefaultExportBufferSize = 10 //MiB
+
+	ExportTimeout = 10 * time.Second
+	//ExportMaxBatch max number of points kept in memory between 2 flushes, new points are dropped once it's full
+	ExportMaxBatch = 100000
+	//ExportUDPPacketSize max size of the udp packets, lines are never split
+	ExportUDPPacketSize = 1400
+	//ExportHTTPLines max number of lines per http request
+	ExportHTTPLines = 5000
+)
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", ``)
+	influxTagEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", ``)
+	statsdEscaper            = strings.NewReplacer(`:`, `_`, `|`, `_`, `,`, `_`, `#`, `_`, "\n", ``)
+
+	//statsdTypes maps the aggregation operations to statsd types, averaged and differential values
+	//are sent as gauges of the raw values
+	statsdTypes = map[Operation]string{
+		Average:      "g",
+		Differential: "g",
+		Gauge:        "g",
+		Counter:      "c",
+		Histogram:    "ms",
+	}
+)
+
+//ExportConfig configures the stats exporter
+type ExportConfig struct {
+	//Target where the stats are pushed, http(s)://host:8086/write?db=zos or udp://host:8089 for the
+	//influxdb line protocol, statsd://host:8125 to forward the stats to a statsd collector
+	Target string
+	//Interval between 2 flushes
+	Interval time.Duration
+	//Buffer file where the stats are kept while the target is not reachable. Only http(s) targets are
+	//buffered, udp writes don't fail when the collector is down so the stats are lost
+	Buffer string
+	//BufferSize max size of the buffer in MiB, new stats are dropped once it's full
+	BufferSize int64
+	//Tags added to all points, the node hostname is added as the `node` tag if not set
+	Tags map[string]string
+}
+
+func (c *ExportConfig) validate() (*url.URL, error) {
+	if c.Interval <= 0 {
+		c.Interval = DefaultExportInterval
+	}
+
+	if c.Buffer == "" {
+		c.Buffer = DefaultExportBuffer
+	}
+
+	if c.BufferSize <= 0 {
+		c.BufferSize = DefaultExportBufferSize
+	}
+
+	u, err := url.Parse(c.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https", "udp", "statsd":
+	default:
+		return nil, fmt.Errorf("invalid export target '%s', expecting http://, https://, udp:// or statsd://", c.Target)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid export target '%s': missing host", c.Target)
+	}
+
+	return u, nil
+}
+
+/*
+Exporter pushes all the reported stats to a remote collector. Stats are batched and flushed every
+interval, either as influxdb line protocol (over http or udp) or as statsd messages. Batches posted
+over http(s) are buffered on disk while the collector is not reachable, and sent once it's back.
+*/
+type Exporter struct {
+	config ExportConfig
+	target *url.URL
+	tags   []pm.Tag
+	client *http.Client
+
+	batch   [][]byte
+	dropped int
+	m       sync.Mutex
+}
+
+//NewExporter creates a stats exporter, it must be registered as a handler on the process manager
+func NewExporter(config ExportConfig) (*Exporter, error) {
+	target, err := config.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Exporter{
+		config: config,
+		target: target,
+		client: &http.Client{Timeout: ExportTimeout},
+	}
+
+	for k, v := range config.Tags {
+		e.tags = append(e.tags, pm.Tag{Key: k, Value: v})
+	}
+
+	if _, ok := config.Tags["node"]; !ok {
+		if hostname, err := os.Hostname(); err == nil {
+			e.tags = append(e.tags, pm.Tag{Key: "node", Value: hostname})
+		}
+	}
+
+	return e, nil
+}
+
+//Start flushing the stats
+func (e *Exporter) Start() {
+	go func() {
+		for range time.Tick(e.config.Interval) {
+			e.flush()
+		}
+	}()
+}
+
+//Stats handler implementation
+func (e *Exporter) Stats(op string, key string, value float64, id string, tags ...pm.Tag) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		//neither influxdb nor statsd accept non finite values
+		return
+	}
+
+	all := make([]pm.Tag, 0, len(tags)+len(e.tags)+1)
+	all = append(all, e.tags...)
+	all = append(all, tags...)
+	if len(id) != 0 {
+		all = append(all, pm.Tag{Key: IDTag, Value: id})
+	}
+
+	var line []byte
+	if e.target.Scheme == "statsd" {
+		line = statsdLine(Operation(op), key, value, all)
+	} else {
+		line = influxLine(key, value, all, time.Now())
+	}
+
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	if len(e.batch) >= ExportMaxBatch {
+		e.dropped++
+		return
+	}
+
+	e.batch = append(e.batch, line)
+}
+
+//influxLine formats a point in the influxdb line protocol
+func influxLine(key string, value float64, tags []pm.Tag, ts time.Time) []byte {
+	sorted := make(Tags, len(tags))
+	copy(sorted, tags)
+	sort.Stable(sorted)
+
+	var buf bytes.Buffer
+	buf.WriteString(influxMeasurementEscaper.Replace(key))
+	for _, tag := range sorted {
+		if tag.Key == "" || tag.Value == "" {
+			//influxdb rejects empty tag keys and values
+			continue
+		}
+
+		fmt.Fprintf(&buf, ",%s=%s", influxTagEscaper.Replace(tag.Key), influxTagEscaper.Replace(tag.Value))
+	}
+
+	fmt.Fprintf(&buf, " value=%s %d", strconv.FormatFloat(value, 'f', -1, 64), ts.UnixNano())
+	return buf.Bytes()
+}
+
+//statsdLine formats a statsd message, tags are sent in the dogstatsd format
+func statsdLine(op Operation, key string, value float64, tags []pm.Tag) []byte {
+	kind, ok := statsdTypes[op]
+	if !ok {
+		kind = "g"
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s:%s|%s", statsdEscaper.Replace(key), strconv.FormatFloat(value, 'f', -1, 64), kind)
+
+	var parts []string
+	for _, tag := range tags {
+		if tag.Key == "" {
+			continue
+		}
+
+		parts = append(parts, fmt.Sprintf("%s:%s", statsdEscaper.Replace(tag.Key), statsdEscaper.Replace(tag.Value)))
+	}
+
+	if len(parts) != 0 {
+		fmt.Fprintf(&buf, "|#%s", strings.Join(parts, ","))
+	}
+
+	return buf.Bytes()
+}
+
+/*
+flush sends the current batch. Over http(s), the batch is appended to the buffer if the target is not
+reachable, and the buffered stats are sent once a batch went through.
+*/
+func (e *Exporter) flush() {
+	e.m.Lock()
+	batch, dropped := e.batch, e.dropped
+	e.batch, e.dropped = nil, 0
+	e.m.Unlock()
+
+	if dropped > 0 {
+		log.Warningf("%d stats were dropped, the export batch is full", dropped)
+	}
+
+	if len(batch) == 0 {
+		return
+	}
+
+	if !e.buffering() {
+		if _, err := e.write(batch); err != nil {
+			log.Errorf("failed to export stats to '%s': %s", e.target.Host, err)
+		}
+
+		return
+	}
+
+	sent, err := e.post(batch)
+	if err != nil {
+		log.Errorf("failed to export stats to '%s': %s", e.target.Host, err)
+		if err := e.buffer(batch[sent:]); err != nil {
+			log.Errorf("failed to buffer stats: %s", err)
+		}
+
+		return
+	}
+
+	//the target is reachable again
+	lines, err := e.buffered()
+	if err != nil {
+		log.Errorf("failed to read stats export buffer: %s", err)
+		return
+	}
+
+	if len(lines) == 0 {
+		return
+	}
+
+	sent, err = e.post(lines)
+	if err != nil {
+		log.Errorf("failed to export buffered stats to '%s': %s", e.target.Host, err)
+	}
+
+	if err := e.rewrite(lines[sent:]); err != nil {
+		log.Errorf("failed to rewrite stats export buffer: %s", err)
+	}
+}
+
+//buffering is only done for http(s) targets, udp writes don't fail when the collector is down
+func (e *Exporter) buffering() bool {
+	return e.target.Scheme == "http" || e.target.Scheme == "https"
+}
+
+//buffered gets the lines kept in the buffer
+func (e *Exporter) buffered() ([][]byte, error) {
+	data, err := ioutil.ReadFile(e.config.Buffer)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return bytes.Split(bytes.TrimSuffix(data, []byte{'\n'}), []byte{'\n'}), nil
+}
+
+//buffer appends the lines to the buffer, lines that don't fit are dropped
+func (e *Exporter) buffer(lines [][]byte) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(path.Dir(e.config.Buffer), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(e.config.Buffer, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	limit := int(e.config.BufferSize*1024*1024 - info.Size())
+	for i, line := range lines {
+		if buf.Len()+len(line)+1 > limit {
+			log.Warningf("stats export buffer is full, dropping %d stats", len(lines)-i)
+			break
+		}
+
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	_, err = file.Write(buf.Bytes())
+	return err
+}
+
+//rewrite replaces the buffer with the lines that were not sent yet
+func (e *Exporter) rewrite(lines [][]byte) error {
+	if len(lines) == 0 {
+		err := os.Remove(e.config.Buffer)
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	tmp := e.config.Buffer + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, e.config.Buffer)
+}
+
+/*
+post the lines to the target, it returns the number of lines that were handled. Requests rejected
+with a client error (other than a timeout or a rate limit) are dropped since they would be rejected
+again.
+*/
+func (e *Exporter) post(lines [][]byte) (int, error) {
+	sent := 0
+	for sent < len(lines) {
+		end := sent + ExportHTTPLines
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		body := bytes.Join(lines[sent:end], []byte{'\n'})
+		response, err := e.client.Post(e.target.String(), "text/plain", bytes.NewReader(body))
+		if err != nil {
+			return sent, err
+		}
+
+		response.Body.Close()
+		if rejected(response.StatusCode) {
+			log.Errorf("stats export to '%s' rejected with %s, dropping %d stats", e.target.Host, response.Status, end-sent)
+		} else if response.StatusCode < 200 || response.StatusCode >= 300 {
+			return sent, fmt.Errorf("unexpected response status: %s", response.Status)
+		}
+
+		sent = end
+	}
+
+	return sent, nil
+}
+
+//rejected checks if a status is a client error that won't succeed if retried
+func rejected(status int) bool {
+	if status == http.StatusRequestTimeout || status == http.StatusTooManyRequests {
+		return false
+	}
+
+	return status >= 400 && status < 500
+}
+
+//write sends the lines over udp, lines are packed in packets of at most ExportUDPPacketSize
+func (e *Exporter) write(lines [][]byte) (int, error) {
+	conn, err := net.DialTimeout("udp", e.target.Host, ExportTimeout)
+	if err != nil {
+		return 0, err
+	}
+
+	defer conn.Close()
+
+	sent := 0
+	var packet bytes.Buffer
+	for i, line := range lines {
+		if packet.Len() != 0 && packet.Len()+len(line)+1 > ExportUDPPacketSize {
+			if _, err := conn.Write(packet.Bytes()); err != nil {
+				return sent, err
+			}
+
+			sent = i
+			packet.Reset()
+		}
+
+		if packet.Len() != 0 {
+			packet.WriteByte('\n')
+		}
+		packet.Write(line)
+	}
+
+	if _, err := conn.Write(packet.Bytes()); err != nil {
+		return sent, err
+	}
+
+	return len(lines), nil
+}
@@ -0,0 +1,174 @@
+package stats
+
+import (
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/pm"
+)
+
+func TestInfluxLine(t *testing.T) {
+	line := influxLine("disk.iops read", 12.5, []pm.Tag{
+		{Key: "type", Value: "phys"},
+		{Key: IDTag, Value: "sd a,1"},
+		{Key: "empty", Value: ""},
+	}, time.Unix(10, 5))
+
+	assert.Equal(t, `disk.iops\ read,id=sd\ a\,1,type=phys value=12.5 10000000005`, string(line))
+}
+
+func TestStatsdLine(t *testing.T) {
+	assert.Equal(t, "http.latency:0.25|ms|#path:/api,id:1",
+		string(statsdLine(Histogram, "http.latency", 0.25, []pm.Tag{{Key: "path", Value: "/api"}, {Key: IDTag, Value: "1"}})))
+	assert.Equal(t, "net.rx_x:100|g", string(statsdLine(Differential, "net.rx:x", 100, nil)))
+	assert.Equal(t, "requests:1|c", string(statsdLine(Counter, "requests", 1, nil)))
+}
+
+func TestExportConfig(t *testing.T) {
+	for _, target := range []string{"tcp://host:80", "http://", "host:8086"} {
+		c := ExportConfig{Target: target}
+		_, err := c.validate()
+		assert.Error(t, err, target)
+	}
+
+	c := ExportConfig{Target: "statsd://collector:8125"}
+	if _, err := c.validate(); assert.NoError(t, err) {
+		assert.Equal(t, DefaultExportInterval, c.Interval)
+		assert.Equal(t, DefaultExportBuffer, c.Buffer)
+	}
+}
+
+func TestExportHTTPBuffering(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var received []string
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusNoContent {
+			w.WriteHeader(status)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		received = append(received, strings.Split(string(body), "\n")...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	exporter, err := NewExporter(ExportConfig{
+		Target: server.URL + "/write?db=zos",
+		Buffer: path.Join(dir, "buffer"),
+		Tags:   map[string]string{"node": "node1"},
+	})
+
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	exporter.Stats(string(Average), "machine.CPU.percent", 10, "0")
+	exporter.flush()
+	exporter.Stats(string(Average), "machine.CPU.percent", 15, "0")
+	exporter.flush()
+
+	//the collector is down, the batches are appended to the buffer
+	assert.Len(t, received, 0)
+	buffered, _ := exporter.buffered()
+	assert.Len(t, buffered, 2)
+
+	status = http.StatusNoContent
+	exporter.Stats(string(Average), "machine.CPU.percent", 20, "0")
+	exporter.flush()
+
+	//the current batch is sent first, then the buffered ones
+	if assert.Len(t, received, 3) {
+		assert.True(t, strings.HasPrefix(received[0], "machine.CPU.percent,id=0,node=node1 value=20 "))
+		assert.True(t, strings.HasPrefix(received[1], "machine.CPU.percent,id=0,node=node1 value=10 "))
+		assert.True(t, strings.HasPrefix(received[2], "machine.CPU.percent,id=0,node=node1 value=15 "))
+	}
+
+	_, err = os.Stat(path.Join(dir, "buffer"))
+	assert.True(t, os.IsNotExist(err))
+
+	//rejected batches are dropped, not buffered
+	status = http.StatusBadRequest
+	exporter.Stats(string(Average), "machine.CPU.percent", 30, "0")
+	exporter.flush()
+
+	_, err = os.Stat(path.Join(dir, "buffer"))
+	assert.True(t, os.IsNotExist(err))
+
+	//rate limited batches are retried
+	status = http.StatusTooManyRequests
+	exporter.Stats(string(Average), "machine.CPU.percent", 40, "0")
+	exporter.flush()
+
+	buffered, _ = exporter.buffered()
+	assert.Len(t, buffered, 1)
+}
+
+func TestExportNonFinite(t *testing.T) {
+	exporter, err := NewExporter(ExportConfig{Target: "statsd://127.0.0.1:8125"})
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	exporter.Stats(string(Gauge), "queue", math.NaN(), "")
+	exporter.Stats(string(Gauge), "queue", math.Inf(1), "")
+	exporter.Stats(string(Gauge), "queue", math.Inf(-1), "")
+	assert.Len(t, exporter.batch, 0)
+
+	exporter.Stats(string(Gauge), "queue", 1, "")
+	assert.Len(t, exporter.batch, 1)
+}
+
+func TestExportUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	exporter, err := NewExporter(ExportConfig{
+		Target: "statsd://" + conn.LocalAddr().String(),
+		Buffer: path.Join(dir, "buffer"),
+		Tags:   map[string]string{"node": "node1"},
+	})
+
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	exporter.Stats(string(Counter), "requests", 1, "")
+	exporter.Stats(string(Gauge), "queue", 3, "")
+	exporter.flush()
+
+	buf := make([]byte, ExportUDPPacketSize)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "requests:1|c|#node:node1\nqueue:3|g|#node:node1", string(buf[:n]))
+	}
+
+	//udp targets are never buffered
+	_, err = os.Stat(path.Join(dir, "buffer"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -4,8 +4,10 @@ import (
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/op/go-logging"
@@ -19,11 +21,23 @@ const (
 	StateKey           = "state:%s:%s"
 	KeyIdSep           = ":"
 	IDTag              = "id"
+	//HistoryKey is the list of the complete samples of a state for a period, formated with the
+	//state key and the period
+	HistoryKey = "history:%s:%d"
+
+	//stateLocks is the number of locks the state updates are spread on
+	stateLocks = 64
 )
 
 var (
-	log     = logging.MustGetLogger("stats")
-	Periods = []int64{300, 3600} //5 min, 1 hour
+	log = logging.MustGetLogger("stats")
+	//Periods of aggregation, 5 min and 1 hour by default, see SetPeriods
+	Periods = []Period{
+		{Duration: 300, History: HistoryLength},
+		{Duration: 3600, History: HistoryLength},
+	}
+	//Expire is how long a metric is kept after it was last reported
+	Expire = 1 * time.Hour
 )
 
 /*
@@ -57,24 +71,92 @@ type Stats struct {
 	Tags      []pm.Tag  `json:"tags"`
 }
 
+//store where the states are kept, and the complete samples are pushed
+type store interface {
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte) error
+	Del(keys ...string) (int64, error)
+	RPush(key string, args ...[]byte) (int64, error)
+	LTrim(key string, start, stop int64) error
+	LRange(key string, start, stop int64) ([][]byte, error)
+}
+
 type redisStatsBuffer struct {
-	db    *transport.Sink
-	cache *cache.Cache
+	db        store
+	cache     *cache.Cache
+	observers []Observer
+
+	//locks serialize the updates of a state, a state is always updated under the same lock
+	locks [stateLocks]sync.Mutex
+	//reservoirs of the histogram values of the current samples, they are kept in memory so they are
+	//not marshalled with the state on every value
+	reservoirs map[string]map[int64][]float64
+	rm         sync.Mutex
 }
 
-func NewLedisStatsAggregator(sink *transport.Sink) pm.StatsHandler {
+//Metric is the current state of a tracked metric
+type Metric struct {
+	Key string
+	*State
+
+	internal string
+}
+
+//ID gets the value of the id tag of the metric, if set
+func (m *Metric) ID() string {
+	for _, t := range m.Tags {
+		if t.Key == IDTag {
+			return t.Value
+		}
+	}
+
+	return ""
+}
+
+//Aggregator aggregates the stats reported to the process manager
+type Aggregator interface {
+	pm.StatsHandler
+	//Metrics gets the current state of all tracked metrics, with the last complete sample of each
+	//period as history
+	Metrics() []Metric
+	//Observe registers an observer of the complete samples
+	Observe(observer Observer)
+}
+
+func NewLedisStatsAggregator(sink *transport.Sink) Aggregator {
 	redisBuffer := &redisStatsBuffer{
 		db:    sink,
-		cache: cache.New(1*time.Hour, 5*time.Minute),
+		cache: cache.New(Expire, 5*time.Minute),
 	}
 
 	redisBuffer.cache.OnEvicted(func(key string, _ interface{}) {
-		if _, err := sink.Del(key); err != nil {
+		keys := []string{key}
+		for _, period := range durations() {
+			keys = append(keys, historyKey(key, period))
+		}
+
+		if _, err := sink.Del(keys...); err != nil {
 			log.Errorf("failed to evict stats key %s", key)
 		}
+
+		redisBuffer.rm.Lock()
+		delete(redisBuffer.reservoirs, key)
+		redisBuffer.rm.Unlock()
 	})
 
+	if SnapshotFile != "" {
+		//states are restored before the aggregator is registered and starts collecting
+		if count, err := redisBuffer.restore(SnapshotFile); err != nil {
+			log.Errorf("failed to restore stats snapshot: %s", err)
+		} else {
+			log.Infof("restored %d stats from snapshot", count)
+		}
+
+		go redisBuffer.snapshots(SnapshotFile, SnapshotInterval)
+	}
+
 	pm.RegisterBuiltIn("aggregator.query", redisBuffer.query)
+	pm.RegisterBuiltIn(cmdAggregatorSnapshot, redisBuffer.snapshot)
 
 	return redisBuffer
 }
@@ -85,43 +167,95 @@ type Point struct {
 	Tags map[string]string `json:"tags,omitempty"`
 }
 
+func (r *redisStatsBuffer) Observe(observer Observer) {
+	r.observers = append(r.observers, observer)
+}
+
+//states loads the current state of all tracked metrics, without their history
+func (r *redisStatsBuffer) states() []Metric {
+	var metrics []Metric
+	for key := range r.cache.Items() {
+		parts := strings.SplitN(key, KeyIdSep, 3) //formated as `StateKey`
+
+		data, err := r.db.Get(key)
+		if err != nil {
+			log.Errorf("failed to get state for metric: %s", key)
+			continue
+		}
+
+		if data == nil {
+			continue
+		}
+
+		state, err := LoadState(data)
+		if err != nil {
+			log.Errorf("failed to load stat for %s", key)
+			continue
+		}
+
+		metrics = append(metrics, Metric{Key: parts[1], internal: key, State: state})
+	}
+
+	return metrics
+}
+
+//Metrics gets the current state of all tracked metrics, the history of each period only holds
+//its last complete sample
+func (r *redisStatsBuffer) Metrics() []Metric {
+	var metrics []Metric
+	for _, metric := range r.states() {
+		metric.History = History{}
+		var err error
+		for _, period := range metric.periods() {
+			if metric.History[period], err = r.history(metric.internal, period, -1); err != nil {
+				break
+			}
+		}
+
+		if err != nil {
+			log.Errorf("failed to load history for %s: %s", metric.internal, err)
+			continue
+		}
+
+		metrics = append(metrics, metric)
+	}
+
+	return metrics
+}
+
 func (r *redisStatsBuffer) query(cmd *pm.Command) (interface{}, error) {
 	var filter struct {
 		Key  string            `json:"key"`
 		Tags map[string]string `json:"tags"`
+		//Period only return the samples of this period
+		Period int64 `json:"period"`
+		//Since and Until only return the history samples in this window (unix timestamps)
+		Since int64 `json:"since"`
+		Until int64 `json:"until"`
 	}
 
 	if err := json.Unmarshal(*cmd.Arguments, &filter); err != nil {
 		return nil, err
 	}
 
+	if filter.Period == 0 && (filter.Since != 0 || filter.Until != 0) {
+		return nil, fmt.Errorf("period is required to query a time window")
+	}
+
 	result := make(map[string]*State)
 
-	for key := range r.cache.Items() {
-		parts := strings.SplitN(key, KeyIdSep, 3) //formated as `StateKey`
-		metric := parts[1]
+	for _, metric := range r.states() {
 		if len(filter.Key) != 0 {
-			if filter.Key != metric {
+			if filter.Key != metric.Key {
 				continue
 			}
 		}
 
-		data, err := r.db.Get(key)
-		if err != nil {
-			log.Errorf("failed to get state for metric: %s", key)
-			continue
-		}
-
-		state, err := LoadState(data)
-		if err != nil {
-			log.Errorf("failed to load stat for %s", key)
-		}
-
 		//filter on tags
 		m := true
 		for k, v := range filter.Tags {
 			m = false
-			for _, t := range state.Tags {
+			for _, t := range metric.Tags {
 				if t.Key == k && t.Value == v {
 					m = true
 					break
@@ -137,19 +271,138 @@ func (r *redisStatsBuffer) query(cmd *pm.Command) (interface{}, error) {
 		}
 
 		//get ID if set
-		for _, t := range state.Tags {
-			if t.Key == IDTag {
-				metric = fmt.Sprintf("%s/%s", metric, t.Value)
-				break
+		key := metric.Key
+		if id := metric.ID(); id != "" {
+			key = fmt.Sprintf("%s/%s", key, id)
+		}
+
+		//the history is only loaded for the matching metrics, and only the samples that can be
+		//in the queried window
+		periods := metric.periods()
+		if filter.Period != 0 {
+			if _, ok := metric.Current[filter.Period]; !ok {
+				return nil, fmt.Errorf("unknown period %d", filter.Period)
+			}
+			periods = []int64{filter.Period}
+		}
+
+		state := metric.State
+		state.History = History{}
+		for _, period := range periods {
+			start, ok := tail(state.Current[period], period, filter.Since)
+			if !ok {
+				continue
+			}
+
+			var err error
+			if state.History[period], err = r.history(metric.internal, period, start); err != nil {
+				return nil, err
 			}
 		}
 
-		result[metric] = state
+		if filter.Period != 0 {
+			var err error
+			if state, err = state.Window(filter.Period, filter.Since, filter.Until); err != nil {
+				return nil, err
+			}
+		}
+
+		result[key] = state
 	}
 
 	return result, nil
 }
 
+//tail gets the index of the first history sample that can start at or after since, relative to the
+//end of the history. The samples are at least a period apart and all started before the current
+//sample, so at most (current - since) / period samples are needed. It's false if no sample can be
+//in the window.
+func tail(current *Sample, period, since int64) (int64, bool) {
+	if since == 0 || current == nil || current.Start == 0 {
+		return 0, true
+	}
+
+	if current.Start <= since {
+		return 0, false
+	}
+
+	return -((current.Start - since + period - 1) / period), true
+}
+
+//historyKey of the history list of a state for a period
+func historyKey(internal string, period int64) string {
+	return fmt.Sprintf(HistoryKey, strings.TrimPrefix(internal, "state"+KeyIdSep), period)
+}
+
+//log pushes a complete sample to the history of its period, only the last samples are kept
+func (r *redisStatsBuffer) log(internal string, period int64, sample *Sample) error {
+	data, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+
+	key := historyKey(internal, period)
+	if _, err := r.db.RPush(key, data); err != nil {
+		return err
+	}
+
+	return r.db.LTrim(key, -int64(historyLength(period)), -1)
+}
+
+//history loads the samples of the history of a state for a period, starting at index start (negative
+//indexes are relative to the end of the history)
+func (r *redisStatsBuffer) history(internal string, period, start int64) ([]Sample, error) {
+	items, err := r.db.LRange(historyKey(internal, period), start, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]Sample, len(items))
+	for i, item := range items {
+		if err := json.Unmarshal(item, &samples[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return samples, nil
+}
+
+//lock gets the lock of a state
+func (r *redisStatsBuffer) lock(internal string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(internal))
+	return &r.locks[h.Sum32()%stateLocks]
+}
+
+//load sets the histogram values kept for the current samples of a state
+func (r *redisStatsBuffer) load(internal string, state *State) {
+	r.rm.Lock()
+	defer r.rm.Unlock()
+
+	for period, values := range r.reservoirs[internal] {
+		if sample, ok := state.Current[period]; ok {
+			sample.Values = values
+		}
+	}
+}
+
+//keep the histogram values of the current samples of a state
+func (r *redisStatsBuffer) keep(internal string, state *State) {
+	r.rm.Lock()
+	defer r.rm.Unlock()
+
+	if r.reservoirs == nil {
+		r.reservoirs = make(map[string]map[int64][]float64)
+	}
+
+	reservoir := make(map[int64][]float64)
+	for period, sample := range state.Current {
+		reservoir[period] = sample.Values
+	}
+
+	r.reservoirs[internal] = reservoir
+}
+
 func (r *redisStatsBuffer) hash(tags []pm.Tag) string {
 	sort.Sort(Tags(tags))
 	return fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%v", tags))))
@@ -166,6 +419,11 @@ func (r *redisStatsBuffer) Stats(op string, key string, value float64, id string
 	//touch key in cache so we know we are tracking this key
 	r.cache.Set(internal, nil, cache.DefaultExpiration)
 
+	//the state is read, updated then written back, concurrent updates of the same state would be lost
+	lock := r.lock(internal)
+	lock.Lock()
+	defer lock.Unlock()
+
 	data, err := r.db.Get(internal)
 	if err != nil {
 		log.Errorf("failed to get value for %s: %s", key, err)
@@ -174,7 +432,7 @@ func (r *redisStatsBuffer) Stats(op string, key string, value float64, id string
 
 	var state *State
 	if data == nil {
-		state = NewState(Operation(op), Periods...)
+		state = NewState(Operation(op), durations()...)
 	} else if state, err = LoadState(data); err != nil {
 		log.Errorf("failed to load state object for %s: %s", key, err)
 		return
@@ -184,6 +442,11 @@ func (r *redisStatsBuffer) Stats(op string, key string, value float64, id string
 		state.Tags = tags
 	}
 
+	if state.Operation == Histogram {
+		r.load(internal, state)
+		defer r.keep(internal, state)
+	}
+
 	for period, sample := range state.Feed(value) {
 		if sample.Start == 0 {
 			//undefined sample
@@ -206,6 +469,14 @@ func (r *redisStatsBuffer) Stats(op string, key string, value float64, id string
 		} else {
 			log.Errorf("statistics point marshal error: %s", err)
 		}
+
+		if err := r.log(internal, period, sample); err != nil {
+			log.Errorf("failed to log history of %s: %s", key, err)
+		}
+
+		for _, observer := range r.observers {
+			observer.Sample(key, state.Tags, period, sample)
+		}
 	}
 
 	data, err = json.Marshal(state)
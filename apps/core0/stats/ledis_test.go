@@ -0,0 +1,134 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/pm"
+)
+
+func TestStatsHistory(t *testing.T) {
+	buffer := &redisStatsBuffer{db: newTestStore(), cache: cache.New(Expire, time.Minute)}
+	buffer.Stats(string(Average), "machine.cpu.percent", 10, "")
+
+	internal := fmt.Sprintf(StateKey, "machine.cpu.percent", buffer.hash(nil))
+	for i := int64(1); i <= HistoryLength+2; i++ {
+		if !assert.NoError(t, buffer.log(internal, 300, &Sample{Avg: float64(i), Count: 1, Start: i * 300})) {
+			t.Fatal()
+		}
+	}
+
+	//the state only keeps the current samples
+	data, _ := buffer.db.Get(internal)
+	state, err := LoadState(data)
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+	assert.Empty(t, state.History)
+
+	//the metrics only load the last sample of each period
+	metrics := buffer.Metrics()
+	if !assert.Len(t, metrics, 1) {
+		t.Fatal()
+	}
+
+	if assert.Len(t, metrics[0].History[300], 1) {
+		assert.Equal(t, float64(HistoryLength+2), metrics[0].History[300][0].Avg)
+	}
+	assert.Empty(t, metrics[0].History[3600])
+
+	//history is capped per period
+	states := query(t, buffer, pm.M{"key": "machine.cpu.percent"})
+	history := states["machine.cpu.percent"].History[300]
+	if assert.Len(t, history, HistoryLength) {
+		assert.Equal(t, 3., history[0].Avg)
+		assert.Equal(t, float64(HistoryLength+2), history[HistoryLength-1].Avg)
+	}
+	assert.Empty(t, states["machine.cpu.percent"].History[3600])
+}
+
+func TestStatsQueryWindow(t *testing.T) {
+	db := newTestStore()
+	buffer := &redisStatsBuffer{db: db, cache: cache.New(Expire, time.Minute)}
+	buffer.Stats(string(Average), "machine.cpu.percent", 10, "")
+
+	internal := fmt.Sprintf(StateKey, "machine.cpu.percent", buffer.hash(nil))
+	data, _ := db.Get(internal)
+	state, err := LoadState(data)
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	current := state.Current[300].Start
+	for i := int64(HistoryLength); i > 0; i-- {
+		if !assert.NoError(t, buffer.log(internal, 300, &Sample{Avg: float64(i), Count: 1, Start: current - i*300})) {
+			t.Fatal()
+		}
+	}
+
+	db.ranged = 0
+	states := query(t, buffer, pm.M{"key": "machine.cpu.percent", "period": 300, "since": current - 3*300, "until": current - 2*300})
+	if assert.Len(t, states["machine.cpu.percent"].History[300], 2) {
+		assert.Equal(t, 3., states["machine.cpu.percent"].History[300][0].Avg)
+	}
+
+	//only the samples that can be in the window are read
+	assert.Equal(t, 3, db.ranged)
+
+	//no sample can be in the window
+	db.ranged = 0
+	states = query(t, buffer, pm.M{"key": "machine.cpu.percent", "period": 300, "since": current})
+	assert.Empty(t, states["machine.cpu.percent"].History[300])
+	assert.Equal(t, 0, db.ranged)
+}
+
+func query(t *testing.T, buffer *redisStatsBuffer, filter pm.M) map[string]*State {
+	result, err := buffer.query(&pm.Command{Arguments: pm.MustArguments(filter)})
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	return result.(map[string]*State)
+}
+
+func TestStatsConcurrent(t *testing.T) {
+	db := newTestStore()
+	db.delay = time.Millisecond
+	buffer := &redisStatsBuffer{db: db, cache: cache.New(Expire, time.Minute)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buffer.Stats(string(Counter), "network.connections", 1, "")
+		}()
+	}
+	wg.Wait()
+
+	//no increment is lost
+	metrics := buffer.Metrics()
+	if assert.Len(t, metrics, 1) {
+		assert.Equal(t, 100., metrics[0].Sum)
+	}
+}
+
+func TestStatsHistogramReservoir(t *testing.T) {
+	buffer := &redisStatsBuffer{db: newTestStore(), cache: cache.New(Expire, time.Minute)}
+	for i := 0; i < 10; i++ {
+		buffer.Stats(string(Histogram), "http.latency", float64(i), "")
+	}
+
+	//the values are kept in memory, not in the state
+	internal := fmt.Sprintf(StateKey, "http.latency", buffer.hash(nil))
+	data, _ := buffer.db.Get(internal)
+	assert.False(t, strings.Contains(string(data), "values"))
+
+	finest := durations()[0]
+	assert.Len(t, buffer.reservoirs[internal][finest], 10)
+}
@@ -0,0 +1,283 @@
+package stats
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zero-os/0-core/base/auth"
+	"github.com/zero-os/0-core/base/pm"
+)
+
+const (
+	//PrometheusDefaultListen default listen address of the metrics endpoint
+	PrometheusDefaultListen = ":9100"
+	//PrometheusPath path of the metrics endpoint
+	PrometheusPath = "/metrics"
+	//PrometheusPrefix is prepended to all metric names
+	PrometheusPrefix = "zos_"
+	//PrometheusCommand callers must be allowed this command by the policy to read the metrics
+	PrometheusCommand = "aggregator.query"
+
+	//self signed certificate used if no certificate is configured
+	prometheusSelfSignedCert = "/var/cache/core-metrics.crt"
+	prometheusSelfSignedKey  = "/var/cache/core-metrics.key"
+)
+
+var (
+	invalidMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+	invalidLabelChars  = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+	labelEscaper       = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+//PrometheusConfig of the metrics endpoint
+type PrometheusConfig struct {
+	//Listen address of the endpoint
+	Listen string
+	//Cert and Key files of the server certificate, a self signed certificate is used if
+	//authentication is configured and no certificate is set. Without a certificate and
+	//authentication, metrics are served over plain http.
+	Cert string
+	Key  string
+	//Auth options, same as the redis proxy
+	Auth auth.Options
+}
+
+//Prometheus serves the current state of the aggregated stats in the prometheus text format
+type Prometheus struct {
+	aggregator Aggregator
+	server     *http.Server
+	tls        bool
+}
+
+//NewPrometheus creates the metrics endpoint
+func NewPrometheus(aggregator Aggregator, c PrometheusConfig) (*Prometheus, error) {
+	p := &Prometheus{
+		aggregator: aggregator,
+	}
+
+	if c.Listen == "" {
+		c.Listen = PrometheusDefaultListen
+	}
+
+	handler, err := auth.HTTP(c.Auth, PrometheusCommand, http.HandlerFunc(p.metrics))
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(PrometheusPath, handler)
+
+	p.server = &http.Server{
+		Addr:    c.Listen,
+		Handler: mux,
+	}
+
+	if p.server.TLSConfig, err = c.tlsConfig(); err != nil {
+		return nil, err
+	}
+
+	p.tls = p.server.TLSConfig != nil
+
+	return p, nil
+}
+
+func (c *PrometheusConfig) tlsConfig() (*tls.Config, error) {
+	if c.Cert == "" && c.Key == "" {
+		if !c.Auth.Enabled() {
+			return nil, nil
+		}
+
+		c.Cert, c.Key = prometheusSelfSignedCert, prometheusSelfSignedKey
+		if err := auth.SelfSigned(c.Cert, c.Key); err != nil {
+			return nil, err
+		}
+	} else if c.Cert == "" || c.Key == "" {
+		return nil, fmt.Errorf("both certificate and key must be set")
+	}
+
+	certs, err := auth.NewCertificates(c.Cert, c.Key, c.Auth.ClientCA)
+	if err != nil {
+		return nil, err
+	}
+
+	certs.Watch()
+	return certs.Config(), nil
+}
+
+//Start serving the metrics
+func (p *Prometheus) Start() {
+	go func() {
+		log.Infof("Starting metrics endpoint on %s", p.server.Addr)
+		var err error
+		if p.tls {
+			err = p.server.ListenAndServeTLS("", "")
+		} else {
+			err = p.server.ListenAndServe()
+		}
+
+		log.Errorf("metrics endpoint error: %s", err)
+	}()
+}
+
+func (p *Prometheus) metrics(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	writeMetrics(&buf, p.aggregator.Metrics())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
+
+//metricName maps a stats key to a prometheus metric name (ex: disk.iops.read -> zos_disk_iops_read)
+func metricName(key string) string {
+	return PrometheusPrefix + invalidMetricChars.ReplaceAllString(key, "_")
+}
+
+//labels renders the metric tags, including the id, as prometheus labels. Extra labels are added after the tags.
+func labels(tags []pm.Tag, extra ...pm.Tag) string {
+	if len(tags) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	sorted := make(Tags, len(tags))
+	copy(sorted, tags)
+	sort.Sort(sorted)
+
+	var parts []string
+	for _, tag := range append(sorted, extra...) {
+		name := invalidLabelChars.ReplaceAllString(tag.Key, "_")
+		if name == "" || (name[0] >= '0' && name[0] <= '9') {
+			name = "_" + name
+		}
+
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, name, labelEscaper.Replace(tag.Value)))
+	}
+
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+//last gets the current sample of the shortest period if it has values, or the last complete sample
+func last(state *State) *Sample {
+	var period int64 = math.MaxInt64
+	for d := range state.Current {
+		if d < period {
+			period = d
+		}
+	}
+
+	if sample, ok := state.Current[period]; ok && sample.Count > 0 {
+		return sample.summary()
+	}
+
+	if history := state.History[period]; len(history) > 0 {
+		return &history[len(history)-1]
+	}
+
+	return nil
+}
+
+//value gets the current value of a metric, the average of the shortest period so far, or of the
+//last complete period if the current one has no values yet
+func value(state *State) (float64, bool) {
+	if sample := last(state); sample != nil {
+		return sample.Avg, true
+	}
+
+	if state.Operation == Average && state.LastTime != -1 {
+		return state.LastValue, true
+	}
+
+	return 0, false
+}
+
+type family struct {
+	kind    string
+	samples []string
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+//writeMetrics renders the metrics in the prometheus text format. Averaged stats are exposed as
+//gauges, differential stats are exposed as a gauge of the rate per second, and a counter of
+//the raw value (with the _total suffix). Gauges are exposed as gauges of the last value, counters
+//as counters of the sum of the increments, and histograms as summaries of the last sample.
+func writeMetrics(w io.Writer, metrics []Metric) {
+	families := make(map[string]*family)
+	add := func(name, kind, sample string) {
+		f, ok := families[name]
+		if !ok {
+			f = &family{kind: kind}
+			families[name] = f
+		}
+
+		f.samples = append(f.samples, sample)
+	}
+
+	for _, metric := range metrics {
+		name := metricName(metric.Key)
+		lbls := labels(metric.Tags)
+
+		switch metric.Operation {
+		case Gauge:
+			if metric.LastTime != -1 {
+				add(name, "gauge", fmt.Sprintf("%s%s %s", name, lbls, formatFloat(metric.LastValue)))
+			}
+			continue
+		case Counter:
+			if metric.LastTime != -1 {
+				total := name + "_total"
+				add(total, "counter", fmt.Sprintf("%s%s %s", total, lbls, formatFloat(metric.Sum)))
+			}
+			continue
+		case Histogram:
+			sample := last(metric.State)
+			if sample == nil {
+				continue
+			}
+
+			for _, p := range Percentiles {
+				quantile := pm.Tag{Key: "quantile", Value: formatFloat(float64(p) / 100)}
+				add(name, "summary", fmt.Sprintf("%s%s %s", name, labels(metric.Tags, quantile),
+					formatFloat(sample.Percentiles[fmt.Sprintf("p%d", p)])))
+			}
+
+			add(name, "summary", fmt.Sprintf("%s_sum%s %s", name, lbls, formatFloat(sample.Total)))
+			add(name, "summary", fmt.Sprintf("%s_count%s %d", name, lbls, sample.Count))
+			continue
+		}
+
+		if v, ok := value(metric.State); ok {
+			add(name, "gauge", fmt.Sprintf("%s%s %s", name, lbls, formatFloat(v)))
+		}
+
+		if metric.Operation == Differential && metric.LastTime != -1 {
+			total := name + "_total"
+			add(total, "counter", fmt.Sprintf("%s%s %s", total, lbls, formatFloat(metric.LastValue)))
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := families[name]
+		sort.Strings(f.samples)
+
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, f.kind)
+		for _, sample := range f.samples {
+			fmt.Fprintln(w, sample)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package stats
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/pm"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	avg := NewState(Average, 300)
+	avg.Tags = []pm.Tag{{Key: "type", Value: "phys"}, {Key: IDTag, Value: "sda"}}
+	avg.FeedOn(10, 100)
+	avg.FeedOn(20, 200)
+
+	diff := NewState(Differential, 300)
+	diff.Tags = []pm.Tag{{Key: IDTag, Value: `eth"0`}}
+	diff.FeedOn(10, 1000)
+	diff.FeedOn(20, 1100)
+
+	var buf bytes.Buffer
+	writeMetrics(&buf, []Metric{
+		{Key: "disk.size.free", State: avg},
+		{Key: "net.rxbytes", State: diff},
+	})
+
+	assert.Equal(t, `# TYPE zos_disk_size_free gauge
+zos_disk_size_free{id="sda",type="phys"} 150
+# TYPE zos_net_rxbytes gauge
+zos_net_rxbytes{id="eth\"0"} 10
+# TYPE zos_net_rxbytes_total counter
+zos_net_rxbytes_total{id="eth\"0"} 1100
+`, buf.String())
+}
+
+func TestWriteMetricTypes(t *testing.T) {
+	gauge := NewState(Gauge, 300)
+	gauge.FeedOn(10, 3)
+	gauge.FeedOn(10, 7)
+
+	counter := NewState(Counter, 300)
+	counter.FeedOn(10, 2)
+	counter.FeedOn(20, 3)
+
+	histogram := NewState(Histogram, 300)
+	histogram.Tags = []pm.Tag{{Key: "path", Value: "/"}}
+	for i := 1; i <= 10; i++ {
+		histogram.FeedOn(10, float64(i))
+	}
+
+	var buf bytes.Buffer
+	writeMetrics(&buf, []Metric{
+		{Key: "queue.size", State: gauge},
+		{Key: "http.requests", State: counter},
+		{Key: "http.latency", State: histogram},
+	})
+
+	assert.Equal(t, `# TYPE zos_http_latency summary
+zos_http_latency_count{path="/"} 10
+zos_http_latency_sum{path="/"} 55
+zos_http_latency{path="/",quantile="0.5"} 5
+zos_http_latency{path="/",quantile="0.9"} 9
+zos_http_latency{path="/",quantile="0.99"} 10
+# TYPE zos_http_requests_total counter
+zos_http_requests_total 5
+# TYPE zos_queue_size gauge
+zos_queue_size 7
+`, buf.String())
+}
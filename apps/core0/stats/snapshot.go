@@ -0,0 +1,200 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"time"
+
+	"github.com/zero-os/0-core/base/pm"
+)
+
+const (
+	//DefaultSnapshotFile on the cache disk
+	DefaultSnapshotFile = "/var/cache/core-stats.snapshot"
+	//DefaultSnapshotInterval between 2 snapshots
+	DefaultSnapshotInterval = 5 * time.Minute
+
+	cmdAggregatorSnapshot = "aggregator.snapshot"
+)
+
+var (
+	//SnapshotFile where the stats states are saved, snapshots are disabled if not set
+	SnapshotFile string
+	//SnapshotInterval between 2 snapshots
+	SnapshotInterval = DefaultSnapshotInterval
+)
+
+//snapshot of the states of all tracked metrics, and of their history lists
+type snapshot struct {
+	Time    int64                        `json:"time"`
+	States  map[string]json.RawMessage   `json:"states"`
+	History map[string][]json.RawMessage `json:"history,omitempty"`
+}
+
+//save writes the states of all tracked metrics to the snapshot file
+func (r *redisStatsBuffer) save(file string) error {
+	s := snapshot{
+		Time:    time.Now().Unix(),
+		States:  make(map[string]json.RawMessage),
+		History: make(map[string][]json.RawMessage),
+	}
+
+	periods := durations()
+
+	for key := range r.cache.Items() {
+		data, err := r.db.Get(key)
+		if err != nil {
+			return err
+		}
+
+		if data == nil {
+			continue
+		}
+
+		s.States[key] = data
+
+		for _, period := range periods {
+			list := historyKey(key, period)
+			items, err := r.db.LRange(list, 0, -1)
+			if err != nil {
+				return err
+			}
+
+			for _, item := range items {
+				s.History[list] = append(s.History[list], item)
+			}
+		}
+	}
+
+	data, err := json.Marshal(&s)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	//write to a temp file then rename, so a crash never leaves a partial snapshot behind
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, file)
+}
+
+//restore loads the states saved in the snapshot file. States of metrics that expired since the
+//snapshot, or that were aggregated on other periods are dropped.
+func (r *redisStatsBuffer) restore(file string) (int, error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	periods := durations()
+	var count int
+	for key, data := range s.States {
+		state, err := LoadState(data)
+		if err != nil {
+			log.Errorf("failed to load stats state %s: %s", key, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(state.periods(), periods) {
+			continue
+		}
+
+		last := time.Unix(state.LastTime, 0)
+		if state.LastTime == -1 {
+			last = time.Unix(s.Time, 0)
+		}
+
+		expire := last.Add(Expire).Sub(now)
+		if expire <= 0 {
+			continue
+		}
+
+		if state.Operation == Differential {
+			//counters are reset by the reboot, the next value is only the reference of the following one
+			state.LastTime = -1
+		}
+
+		//the history is kept in its own lists
+		state.History = nil
+		for _, period := range periods {
+			list := historyKey(key, period)
+			if _, err := r.db.Del(list); err != nil {
+				return count, err
+			}
+
+			items := s.History[list]
+			if len(items) == 0 {
+				continue
+			}
+
+			values := make([][]byte, 0, len(items))
+			for _, item := range items {
+				values = append(values, item)
+			}
+
+			if _, err := r.db.RPush(list, values...); err != nil {
+				return count, err
+			}
+		}
+
+		if data, err = json.Marshal(state); err != nil {
+			return count, err
+		}
+
+		if err := r.db.Set(key, data); err != nil {
+			return count, err
+		}
+
+		r.cache.Set(key, nil, expire)
+		count++
+	}
+
+	return count, nil
+}
+
+//snapshots saves the states periodically
+func (r *redisStatsBuffer) snapshots(file string, interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := r.save(file); err != nil {
+			log.Errorf("failed to save stats snapshot: %s", err)
+		}
+	}
+}
+
+//Shutdown implements pm.ShutdownHandler, the states are saved before a reboot or a power off
+func (r *redisStatsBuffer) Shutdown() {
+	if SnapshotFile == "" {
+		return
+	}
+
+	if err := r.save(SnapshotFile); err != nil {
+		log.Errorf("failed to save stats snapshot: %s", err)
+	}
+}
+
+//aggregator.snapshot
+func (r *redisStatsBuffer) snapshot(cmd *pm.Command) (interface{}, error) {
+	if SnapshotFile == "" {
+		return nil, fmt.Errorf("stats snapshots are not enabled")
+	}
+
+	return nil, r.save(SnapshotFile)
+}
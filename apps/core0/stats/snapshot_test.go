@@ -0,0 +1,215 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/pm"
+)
+
+type testStore struct {
+	m      sync.Mutex
+	values map[string][]byte
+	lists  map[string][][]byte
+	//delay of the reads, to widen the window between reading and writing a state
+	delay time.Duration
+	//ranged counts the list items read
+	ranged int
+}
+
+func newTestStore() *testStore {
+	return &testStore{
+		values: make(map[string][]byte),
+		lists:  make(map[string][][]byte),
+	}
+}
+
+func (s *testStore) Get(key string) ([]byte, error) {
+	s.m.Lock()
+	value := s.values[key]
+	s.m.Unlock()
+
+	time.Sleep(s.delay)
+	return value, nil
+}
+
+func (s *testStore) Set(key string, value []byte) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.values[key] = value
+	return nil
+}
+
+func (s *testStore) Del(keys ...string) (int64, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for _, key := range keys {
+		delete(s.values, key)
+		delete(s.lists, key)
+	}
+
+	return int64(len(keys)), nil
+}
+
+func (s *testStore) RPush(key string, args ...[]byte) (int64, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.lists[key] = append(s.lists[key], args...)
+	return int64(len(s.lists[key])), nil
+}
+
+//span of a list range, negative indexes are counted from the end like in redis
+func span(length int, start, stop int64) (int, int) {
+	if start < 0 {
+		start += int64(length)
+	}
+
+	if stop < 0 {
+		stop += int64(length)
+	}
+
+	if start < 0 {
+		start = 0
+	}
+
+	if stop >= int64(length) {
+		stop = int64(length) - 1
+	}
+
+	if start > stop {
+		return 0, 0
+	}
+
+	return int(start), int(stop) + 1
+}
+
+func (s *testStore) LTrim(key string, start, stop int64) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	from, to := span(len(s.lists[key]), start, stop)
+	s.lists[key] = s.lists[key][from:to]
+	return nil
+}
+
+func (s *testStore) LRange(key string, start, stop int64) ([][]byte, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	from, to := span(len(s.lists[key]), start, stop)
+	s.ranged += to - from
+	return s.lists[key][from:to], nil
+}
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "stats.snapshot")
+
+	buffer := &redisStatsBuffer{db: newTestStore(), cache: cache.New(Expire, time.Minute)}
+	now := time.Now().Unix()
+	buffer.Stats(string(Average), "machine.memory.ram.available", 100, "")
+	buffer.Stats(string(Differential), "network.packets.rx", 1000, "eth0", pm.Tag{Key: "type", Value: "phys"})
+
+	internal := fmt.Sprintf(StateKey, "machine.memory.ram.available", buffer.hash(nil))
+	if !assert.NoError(t, buffer.log(internal, 300, &Sample{Avg: 50, Count: 1, Start: now - 300})) {
+		t.Fatal()
+	}
+
+	if !assert.NoError(t, buffer.save(file)) {
+		t.Fatal()
+	}
+
+	restored := &redisStatsBuffer{db: newTestStore(), cache: cache.New(Expire, time.Minute)}
+	count, err := restored.restore(file)
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	assert.Equal(t, 2, count)
+	metrics := restored.Metrics()
+	if assert.Len(t, metrics, 2) {
+		for _, metric := range metrics {
+			if metric.Operation == Differential {
+				//counters are reset by the reboot
+				assert.Equal(t, int64(-1), metric.LastTime)
+				assert.Equal(t, "eth0", metric.ID())
+			} else {
+				assert.Equal(t, 100., metric.LastValue)
+				assert.InDelta(t, now, metric.LastTime, 1)
+				//the history is restored with the state
+				if assert.Len(t, metric.History[300], 1) {
+					assert.Equal(t, 50., metric.History[300][0].Avg)
+				}
+			}
+		}
+	}
+
+	//expired metrics are not restored
+	var s snapshot
+	data, _ := ioutil.ReadFile(file)
+	if !assert.NoError(t, json.Unmarshal(data, &s)) {
+		t.Fatal()
+	}
+
+	for key, data := range s.States {
+		state, _ := LoadState(data)
+		state.LastTime = now - int64(Expire/time.Second) - 1
+		s.States[key], _ = json.Marshal(state)
+	}
+
+	data, _ = json.Marshal(&s)
+	ioutil.WriteFile(file, data, 0600)
+
+	count, err = (&redisStatsBuffer{db: newTestStore(), cache: cache.New(Expire, time.Minute)}).restore(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	//a missing snapshot is not an error
+	count, err = restored.restore(path.Join(dir, "missing"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestSnapshotShutdown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(file string) {
+		SnapshotFile = file
+	}(SnapshotFile)
+
+	SnapshotFile = path.Join(dir, "stats.snapshot")
+
+	buffer := &redisStatsBuffer{db: newTestStore(), cache: cache.New(Expire, time.Minute)}
+	buffer.Stats(string(Average), "machine.memory.ram.available", 100, "")
+
+	//the states are saved before the system goes down
+	var handler pm.Handler = buffer
+	if !assert.Implements(t, (*pm.ShutdownHandler)(nil), handler) {
+		t.Fatal()
+	}
+	handler.(pm.ShutdownHandler).Shutdown()
+
+	count, err := (&redisStatsBuffer{db: newTestStore(), cache: cache.New(Expire, time.Minute)}).restore(SnapshotFile)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
@@ -2,26 +2,105 @@ package stats
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/zero-os/0-core/base/pm"
 	"math"
+	"math/rand"
+	"sort"
 	"time"
 )
 
 const (
 	Average      Operation = "A"
 	Differential Operation = "D"
-
+	//Gauge keeps the last reported value
+	Gauge Operation = "G"
+	//Counter sums the reported increments, negative increments are ignored
+	Counter Operation = "C"
+	//Histogram computes the percentiles of the reported values
+	Histogram Operation = "H"
+
+	//HistoryLength default number of samples kept for each period
 	HistoryLength = 5
+	//HistogramValues max number of values kept per sample to compute the percentiles
+	HistogramValues = 1024
 )
 
+//Percentiles computed for the histogram samples
+var Percentiles = []int{50, 90, 99}
+
+//Period is an aggregation period in seconds, and the number of samples kept in its history
+type Period struct {
+	Duration int64 `json:"duration"`
+	History  int   `json:"history"`
+}
+
+//SetPeriods configures the aggregation periods. Periods are sorted from the finest to the coarsest,
+//each period must be a multiple of the finer one, since coarser samples are computed from the finer ones.
+func SetPeriods(periods []Period) error {
+	if len(periods) == 0 {
+		return fmt.Errorf("at least one period is required")
+	}
+
+	sorted := make([]Period, len(periods))
+	copy(sorted, periods)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Duration < sorted[j].Duration
+	})
+
+	for i, period := range sorted {
+		if period.Duration <= 0 {
+			return fmt.Errorf("invalid period duration %d", period.Duration)
+		}
+
+		if period.History <= 0 {
+			sorted[i].History = HistoryLength
+		}
+
+		if i > 0 && period.Duration%sorted[i-1].Duration != 0 {
+			return fmt.Errorf("period %d is not a multiple of period %d", period.Duration, sorted[i-1].Duration)
+		}
+	}
+
+	Periods = sorted
+	return nil
+}
+
+//durations of the configured periods
+func durations() []int64 {
+	var durations []int64
+	for _, period := range Periods {
+		durations = append(durations, period.Duration)
+	}
+
+	return durations
+}
+
+//historyLength of a period
+func historyLength(duration int64) int {
+	for _, period := range Periods {
+		if period.Duration == duration {
+			return period.History
+		}
+	}
+
+	return HistoryLength
+}
+
 type Operation string
 
 type Sample struct {
 	Avg   float64 `json:"avg"`
 	Total float64 `json:"total"`
 	Max   float64 `json:"max"`
+	Last  float64 `json:"last"`
 	Count uint    `json:"count"`
 	Start int64   `json:"start"`
+	//Percentiles of the histogram samples, set once the sample is complete
+	Percentiles map[string]float64 `json:"percentiles,omitempty"`
+	//Values sampled (reservoir) from the reported values of a histogram sample, they are not saved
+	//with the state
+	Values []float64 `json:"-"`
 }
 
 /*
@@ -38,8 +117,10 @@ func (m *Sample) Feed(value float64, now int64, duration int64) *Sample {
 		m.Total = value
 		m.Avg = value
 		m.Max = value
+		m.Last = value
 		m.Count = 1
 		m.Start = period
+		m.Values = nil
 
 		return &update
 	}
@@ -51,6 +132,7 @@ func (m *Sample) Feed(value float64, now int64, duration int64) *Sample {
 	m.Total += value
 	m.Count += 1
 	m.Avg = m.Total / float64(m.Count)
+	m.Last = value
 	if value > m.Max {
 		m.Max = value
 	}
@@ -58,6 +140,119 @@ func (m *Sample) Feed(value float64, now int64, duration int64) *Sample {
 	return nil
 }
 
+//observe keeps a value for the percentiles. The sample keeps at most HistogramValues values, picked
+//uniformly from all the values fed to the sample (reservoir sampling). It must be called after Feed.
+func (m *Sample) observe(value float64) {
+	if len(m.Values) < HistogramValues {
+		m.Values = append(m.Values, value)
+	} else if i := rand.Int63n(int64(m.Count)); i < HistogramValues {
+		m.Values[i] = value
+	}
+}
+
+//mergeValues merges the values kept for 2 samples of count a and b, the merged values are picked from
+//each sample in proportion of its count
+func mergeValues(values []float64, a uint, other []float64, b uint) []float64 {
+	if len(values)+len(other) <= HistogramValues {
+		merged := make([]float64, 0, len(values)+len(other))
+		merged = append(merged, values...)
+		return append(merged, other...)
+	}
+
+	n := int(math.Round(float64(HistogramValues) * float64(a) / float64(a+b)))
+	if n > len(values) {
+		n = len(values)
+	} else if HistogramValues-n > len(other) {
+		n = HistogramValues - len(other)
+	}
+
+	merged := make([]float64, 0, HistogramValues)
+	for _, i := range rand.Perm(len(values))[:n] {
+		merged = append(merged, values[i])
+	}
+
+	for _, i := range rand.Perm(len(other))[:HistogramValues-n] {
+		merged = append(merged, other[i])
+	}
+
+	return merged
+}
+
+//percentile of sorted values (nearest rank)
+func percentile(sorted []float64, p int) float64 {
+	rank := (p*len(sorted)+99)/100 - 1
+	if rank < 0 {
+		rank = 0
+	}
+
+	return sorted[rank]
+}
+
+//summary gets a copy of a complete sample, with the percentiles computed from the kept values, and
+//without the values
+func (m Sample) summary() *Sample {
+	if len(m.Values) != 0 {
+		sorted := make([]float64, len(m.Values))
+		copy(sorted, m.Values)
+		sort.Float64s(sorted)
+
+		m.Percentiles = make(map[string]float64)
+		for _, p := range Percentiles {
+			m.Percentiles[fmt.Sprintf("p%d", p)] = percentile(sorted, p)
+		}
+	}
+
+	m.Values = nil
+	return &m
+}
+
+//merge adds a complete sample of a finer period. It returns the current sample if the finer
+//sample belongs to a new period.
+func (m *Sample) merge(sample *Sample, duration int64) *Sample {
+	if sample.Count == 0 {
+		return nil
+	}
+
+	period := (sample.Start / duration) * duration
+
+	var update *Sample
+	if m.Count != 0 && m.Start < period {
+		complete := *m
+		update = &complete
+		*m = Sample{}
+	}
+
+	if m.Count == 0 {
+		*m = *sample
+		m.Start = period
+		m.Values = mergeValues(nil, 0, sample.Values, sample.Count)
+		return update
+	}
+
+	m.Values = mergeValues(m.Values, m.Count, sample.Values, sample.Count)
+	m.Total += sample.Total
+	m.Count += sample.Count
+	m.Avg = m.Total / float64(m.Count)
+	m.Last = sample.Last
+	if sample.Max > m.Max {
+		m.Max = sample.Max
+	}
+
+	return update
+}
+
+//roll ends the current sample if now is in a new period, it returns the complete sample
+func (m *Sample) roll(now int64, duration int64) *Sample {
+	period := (now / duration) * duration
+	if m.Count == 0 || m.Start >= period {
+		return nil
+	}
+
+	complete := *m
+	*m = Sample{Start: period}
+	return &complete
+}
+
 type Samples map[int64]*Sample
 type History map[int64][]Sample
 
@@ -65,16 +260,18 @@ type State struct {
 	Operation Operation `json:"op"`
 	LastValue float64   `json:"last_value"`
 	LastTime  int64     `json:"last_time"`
+	Sum       float64   `json:"sum,omitempty"` //sum of all the increments of a counter
 	Tags      []pm.Tag  `json:"tags,omitempty"`
 	Current   Samples   `json:"current"`
-	History   History   `json:"history"`
+	//History of the complete samples, it is kept in redis lists (see HistoryKey) and only loaded to
+	//query the metrics
+	History History `json:"history,omitempty"`
 }
 
 func NewState(op Operation, durations ...int64) *State {
 	s := State{
 		Operation: op,
 		Current:   Samples{},
-		History:   History{},
 		LastTime:  -1,
 	}
 
@@ -90,35 +287,53 @@ func LoadState(data []byte) (*State, error) {
 	return &state, json.Unmarshal(data, &state)
 }
 
-func (s *State) avg(now int64, value float64) {
-	for d, sample := range s.Current {
-		sample.Feed(value, now, d)
+//periods gets the durations of the state periods, from the finest to the coarsest
+func (s *State) periods() []int64 {
+	var periods []int64
+	for d := range s.Current {
+		periods = append(periods, d)
 	}
+
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i] < periods[j]
+	})
+
+	return periods
 }
 
 func (s *State) init(now int64, value float64) {
-	for d, sample := range s.Current {
-		if s.Operation == Average {
-			sample.Feed(value, now, d)
-		}
+	periods := s.periods()
+	if len(periods) == 0 {
+		return
 	}
-}
 
-func (s *State) log(period int64, sample *Sample) {
-	if sample.Start == 0 {
-		//undefined sample, probably the first one
-		return
+	//only the finest period is fed with values, coarser periods are computed from it. The first
+	//value of a differential is only the reference of the next one
+	if s.Operation != Differential {
+		s.feed(periods[0], value, now)
 	}
+}
 
-	his := s.History[period]
-	his = append(his, *sample)
-	if len(his) > HistoryLength {
-		his = his[len(his)-HistoryLength : len(his)]
+//feed a value to the sample of the finest period
+func (s *State) feed(period int64, value float64, now int64) *Sample {
+	sample := s.Current[period]
+	update := sample.Feed(value, now, period)
+
+	switch s.Operation {
+	case Counter:
+		s.Sum += value
+	case Histogram:
+		sample.observe(value)
 	}
 
-	s.History[period] = his
+	return update
 }
 
+/*
+FeedOn feeds a value reported at now. Values are aggregated in the finest period, and each complete
+sample is merged into the next coarser period (downsampling), so all periods are consistent. It returns
+the samples of the periods that are complete.
+*/
 func (s *State) FeedOn(now int64, value float64) Samples {
 	orig := value
 	defer func() {
@@ -126,13 +341,18 @@ func (s *State) FeedOn(now int64, value float64) Samples {
 		s.LastTime = now
 	}()
 
+	if math.IsNaN(value) || (s.Operation == Counter && value < 0) {
+		//ignore value.
+		return nil
+	}
+
 	if s.LastTime == -1 {
 		s.init(now, value)
 		return nil
 	}
 
-	if s.LastTime >= now {
-		//repeated value
+	if s.LastTime >= now && (s.Operation == Average || s.Operation == Differential) {
+		//repeated value, gauges, counters and histograms accept many values per second
 		return nil
 	}
 
@@ -146,11 +366,38 @@ func (s *State) FeedOn(now int64, value float64) Samples {
 	}
 
 	updates := Samples{}
-	for d, sample := range s.Current {
-		if update := sample.Feed(value, now, d); update != nil {
-			updates[d] = update
-			s.log(d, update)
+	periods := s.periods()
+	if len(periods) == 0 {
+		return updates
+	}
+
+	finest := periods[0]
+	update := s.feed(finest, value, now)
+	if update == nil {
+		return updates
+	}
+
+	updates[finest] = update.summary()
+
+	for _, d := range periods[1:] {
+		sample := s.Current[d]
+		var complete *Sample
+		if c := sample.merge(update, d); c != nil {
+			complete = c
+			updates[d] = c.summary()
+		}
+
+		//the coarser period ends with the finer one
+		if c := sample.roll(now, d); c != nil {
+			complete = c
+			updates[d] = c.summary()
+		}
+
+		if complete == nil {
+			break
 		}
+
+		update = complete
 	}
 
 	return updates
@@ -159,3 +406,32 @@ func (s *State) FeedOn(now int64, value float64) Samples {
 func (s *State) Feed(value float64) Samples {
 	return s.FeedOn(time.Now().Unix(), value)
 }
+
+//Window gets a copy of the state with only the samples of the given period, and the history samples
+//that started between since and until (unix timestamps, ignored if 0)
+func (s *State) Window(period int64, since, until int64) (*State, error) {
+	sample, ok := s.Current[period]
+	if !ok {
+		return nil, fmt.Errorf("unknown period %d", period)
+	}
+
+	window := *s
+	window.Current = Samples{period: sample}
+	window.History = History{}
+
+	var history []Sample
+	for _, sample := range s.History[period] {
+		if since != 0 && sample.Start < since {
+			continue
+		}
+
+		if until != 0 && sample.Start > until {
+			continue
+		}
+
+		history = append(history, sample)
+	}
+
+	window.History[period] = history
+	return &window, nil
+}
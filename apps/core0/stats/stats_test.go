@@ -57,11 +57,12 @@ func assertUpdates(t *testing.T, period int64, updates Samples, total float64, c
 		t.Fatal()
 	}
 
-	if !assert.Equal(t, float64(total)/float64(count), sample.Avg) {
+	//coarser periods are summed from the finer samples, so the sums order differ
+	if !assert.InDelta(t, float64(total)/float64(count), sample.Avg, 1e-9) {
 		t.Fail()
 	}
 
-	if !assert.Equal(t, total, sample.Total) {
+	if !assert.InDelta(t, total, sample.Total, 1e-9) {
 		t.Fail()
 	}
 }
@@ -144,3 +145,136 @@ func TestStateDiff(t *testing.T) {
 		t.Fatal()
 	}
 }
+
+func TestStateDownsampling(t *testing.T) {
+	defer func(periods []Period) {
+		Periods = periods
+	}(Periods)
+
+	if !assert.NoError(t, SetPeriods([]Period{{Duration: 100, History: 2}, {Duration: 10, History: 20}})) {
+		t.Fatal()
+	}
+
+	assert.Equal(t, []int64{10, 100}, durations())
+
+	state := NewState(Average, durations()...)
+	history := History{}
+	for i := int64(1); i <= 300; i++ {
+		for period, sample := range state.FeedOn(i, float64(i%100)) {
+			if sample.Start != 0 {
+				history[period] = append(history[period], *sample)
+			}
+		}
+	}
+
+	assert.Len(t, history[10], 29)
+	if assert.Len(t, history[100], 2) {
+		//[100, 200) is computed from the 10s samples
+		sample := history[100][0]
+		assert.Equal(t, int64(100), sample.Start)
+		assert.Equal(t, uint(100), sample.Count)
+		assert.Equal(t, 99., sample.Max)
+		assert.InDelta(t, 49.5, sample.Avg, 1e-9)
+	}
+
+	state.History = history
+	window, err := state.Window(10, 250, 280)
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	assert.Len(t, window.Current, 1)
+	if assert.Len(t, window.History[10], 4) {
+		assert.Equal(t, int64(250), window.History[10][0].Start)
+	}
+
+	_, err = state.Window(60, 0, 0)
+	assert.Error(t, err)
+
+	assert.Error(t, SetPeriods([]Period{{Duration: 60}, {Duration: 90}}))
+}
+
+func TestStateGauge(t *testing.T) {
+	var p int64 = 50
+	state := NewState(Gauge, p)
+
+	state.FeedOn(0, 10)
+	state.FeedOn(10, 30)
+	state.FeedOn(10, 20)
+
+	updates := state.FeedOn(50, 5)
+	sample, ok := updates[p]
+	if !assert.True(t, ok) {
+		t.Fatal()
+	}
+
+	assert.Equal(t, 20., sample.Last)
+	assert.Equal(t, 30., sample.Max)
+	assert.Equal(t, uint(3), sample.Count)
+	assert.Equal(t, 5., state.LastValue)
+}
+
+func TestStateCounter(t *testing.T) {
+	var p int64 = 50
+	state := NewState(Counter, p)
+
+	state.FeedOn(0, 1)
+	state.FeedOn(10, 2)
+	state.FeedOn(10, 3)
+	state.FeedOn(20, -10) //ignored
+
+	updates := state.FeedOn(50, 4)
+	sample, ok := updates[p]
+	if !assert.True(t, ok) {
+		t.Fatal()
+	}
+
+	assert.Equal(t, 6., sample.Total)
+	assert.Equal(t, uint(3), sample.Count)
+	assert.Equal(t, 10., state.Sum)
+}
+
+func TestStateHistogram(t *testing.T) {
+	var p int64 = 50
+	state := NewState(Histogram, p, p*2)
+
+	for i := 1; i <= 100; i++ {
+		state.FeedOn(100+int64(i%50), float64(i))
+	}
+
+	updates := state.FeedOn(150, 1000)
+	sample, ok := updates[p]
+	if !assert.True(t, ok) {
+		t.Fatal()
+	}
+
+	assert.Equal(t, map[string]float64{"p50": 50, "p90": 90, "p99": 99}, sample.Percentiles)
+	assert.Nil(t, sample.Values)
+
+	//the coarser period is computed from the values kept by the finer one
+	for i := int64(151); i < 200; i++ {
+		state.FeedOn(i, 1000)
+	}
+
+	updates = state.FeedOn(200, 0)
+	sample, ok = updates[p*2]
+	if !assert.True(t, ok) {
+		t.Fatal()
+	}
+
+	assert.Equal(t, uint(150), sample.Count)
+	assert.Equal(t, map[string]float64{"p50": 75, "p90": 1000, "p99": 1000}, sample.Percentiles)
+}
+
+func TestHistogramValuesBounded(t *testing.T) {
+	var s Sample
+	for i := 0; i < 10*HistogramValues; i++ {
+		s.Feed(float64(i), 0, 50)
+		s.observe(float64(i))
+	}
+
+	assert.Len(t, s.Values, HistogramValues)
+
+	merged := mergeValues(s.Values, s.Count, []float64{1, 2, 3}, 3)
+	assert.Len(t, merged, HistogramValues)
+}
@@ -0,0 +1,240 @@
+package cgroups
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+//Accounting subsystems, a container gets its own group in each of them unless it was created in a group
+//of the same subsystem
+var Accounting = []Subsystem{CPUAcctSubsystem, MemorySubsystem, BlkioSubsystem, PidsSubsystem}
+
+func mkAccountingGroup(name string, subsys Subsystem) Group {
+	return &accountingCGroup{
+		cgroup{name: name, subsys: subsys},
+	}
+}
+
+//accountingCGroup is a group only used for accounting, it has no limits
+type accountingCGroup struct {
+	cgroup
+}
+
+func (g *accountingCGroup) Root() Group {
+	return &accountingCGroup{
+		cgroup: cgroup{subsys: g.subsys},
+	}
+}
+
+func (g *accountingCGroup) Reset() {}
+
+type CPUUsage struct {
+	//Usage total cpu time in nanoseconds
+	Usage uint64 `json:"usage"`
+}
+
+type MemoryUsage struct {
+	//Usage in bytes, including the cache
+	Usage uint64 `json:"usage"`
+	RSS   uint64 `json:"rss"`
+	Cache uint64 `json:"cache"`
+}
+
+type BlkioUsage struct {
+	ReadBytes  uint64 `json:"read_bytes"`
+	WriteBytes uint64 `json:"write_bytes"`
+	ReadOps    uint64 `json:"read_ops"`
+	WriteOps   uint64 `json:"write_ops"`
+}
+
+type PidsUsage struct {
+	Current uint64 `json:"current"`
+}
+
+//Usage is the resources accounting of the groups of a process, subsystems where the process is
+//not in a group (or in the root group) are not set
+type Usage struct {
+	CPU    *CPUUsage    `json:"cpu,omitempty"`
+	Memory *MemoryUsage `json:"memory,omitempty"`
+	Blkio  *BlkioUsage  `json:"blkio,omitempty"`
+	Pids   *PidsUsage   `json:"pids,omitempty"`
+}
+
+//parsePaths parses /proc/<pid>/cgroup
+func parsePaths(data []byte) map[Subsystem]string {
+	paths := make(map[Subsystem]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		//hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		for _, controller := range strings.Split(parts[1], ",") {
+			paths[Subsystem(controller)] = parts[2]
+		}
+	}
+
+	return paths
+}
+
+//Paths gets the groups of a process, per subsystem
+func Paths(pid int) (map[Subsystem]string, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePaths(data), nil
+}
+
+//ProcessUsage gets the resources accounting of the groups of a process
+func ProcessUsage(pid int) (*Usage, error) {
+	paths, err := Paths(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	return usage(CGroupBase, paths)
+}
+
+func usage(base string, paths map[Subsystem]string) (*Usage, error) {
+	var usage Usage
+	for _, subsystem := range Accounting {
+		p, ok := paths[subsystem]
+		if !ok || p == "/" || !Available(subsystem) {
+			continue
+		}
+
+		dir := path.Join(base, string(subsystem), p)
+
+		var err error
+		switch subsystem {
+		case CPUAcctSubsystem:
+			usage.CPU, err = cpuUsage(dir)
+		case MemorySubsystem:
+			usage.Memory, err = memoryUsage(dir)
+		case BlkioSubsystem:
+			usage.Blkio, err = blkioUsage(dir)
+		case PidsSubsystem:
+			usage.Pids, err = pidsUsage(dir)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &usage, nil
+}
+
+func readUint(name string) (uint64, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func cpuUsage(dir string) (*CPUUsage, error) {
+	value, err := readUint(path.Join(dir, "cpuacct.usage"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &CPUUsage{Usage: value}, nil
+}
+
+func memoryUsage(dir string) (*MemoryUsage, error) {
+	var usage MemoryUsage
+	var err error
+	if usage.Usage, err = readUint(path.Join(dir, "memory.usage_in_bytes")); err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path.Join(dir, "memory.stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]uint64)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			stats[fields[0]] = value
+		}
+	}
+
+	//total_* values include the sub groups
+	usage.RSS, usage.Cache = stats["total_rss"], stats["total_cache"]
+	if _, ok := stats["total_rss"]; !ok {
+		usage.RSS, usage.Cache = stats["rss"], stats["cache"]
+	}
+
+	return &usage, nil
+}
+
+//blkioTotals sums the Read and Write values of all devices of a blkio stat file
+func blkioTotals(name string) (read, write uint64, err error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		//major:minor operation value
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		switch fields[1] {
+		case "Read":
+			read += value
+		case "Write":
+			write += value
+		}
+	}
+
+	return read, write, nil
+}
+
+func blkioUsage(dir string) (*BlkioUsage, error) {
+	//the throttle stats are available with all io schedulers
+	var usage BlkioUsage
+	var err error
+	if usage.ReadBytes, usage.WriteBytes, err = blkioTotals(path.Join(dir, "blkio.throttle.io_service_bytes")); err != nil {
+		return nil, err
+	}
+
+	if usage.ReadOps, usage.WriteOps, err = blkioTotals(path.Join(dir, "blkio.throttle.io_serviced")); err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+func pidsUsage(dir string) (*PidsUsage, error) {
+	value, err := readUint(path.Join(dir, "pids.current"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &PidsUsage{Current: value}, nil
+}
@@ -0,0 +1,76 @@
+package cgroups
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePaths(t *testing.T) {
+	paths := parsePaths([]byte(`12:pids:/core-1
+11:cpu,cpuacct:/core-1
+5:memory:/mem
+2:blkio:/
+`))
+
+	assert.Equal(t, map[Subsystem]string{
+		PidsSubsystem:    "/core-1",
+		"cpu":            "/core-1",
+		CPUAcctSubsystem: "/core-1",
+		MemorySubsystem:  "/mem",
+		BlkioSubsystem:   "/",
+	}, paths)
+}
+
+func TestUsage(t *testing.T) {
+	base, err := ioutil.TempDir("", "cgroups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	files := map[string]string{
+		"cpuacct/core-1/cpuacct.usage":                 "2500000000\n",
+		"memory/mem/memory.usage_in_bytes":             "4096\n",
+		"memory/mem/memory.stat":                       "cache 100\nrss 200\ntotal_cache 1024\ntotal_rss 2048\n",
+		"pids/core-1/pids.current":                     "7\n",
+		"blkio/core-1/blkio.throttle.io_service_bytes": "8:0 Read 1024\n8:0 Write 512\n8:0 Sync 10\n8:16 Read 1024\nTotal 2560\n",
+	}
+
+	for name, content := range files {
+		name = path.Join(base, name)
+		if err := os.MkdirAll(path.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	usage, err := usage(base, map[Subsystem]string{
+		CPUAcctSubsystem: "/core-1",
+		MemorySubsystem:  "/mem",
+		PidsSubsystem:    "/core-1",
+		BlkioSubsystem:   "/",
+	})
+
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	assert.Equal(t, &CPUUsage{Usage: 2500000000}, usage.CPU)
+	assert.Equal(t, &MemoryUsage{Usage: 4096, RSS: 2048, Cache: 1024}, usage.Memory)
+	assert.Equal(t, &PidsUsage{Current: 7}, usage.Pids)
+	//the root group is not accounted
+	assert.Nil(t, usage.Blkio)
+
+	read, write, err := blkioTotals(path.Join(base, "blkio/core-1/blkio.throttle.io_service_bytes"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint64(2048), read)
+		assert.Equal(t, uint64(512), write)
+	}
+}
@@ -34,6 +34,12 @@ const (
 	CPUSetSubsystem = Subsystem("cpuset")
 	//MemorySubsystem memory subsystem
 	MemorySubsystem = Subsystem("memory")
+	//CPUAcctSubsystem cpu accounting subsystem
+	CPUAcctSubsystem = Subsystem("cpuacct")
+	//BlkioSubsystem block io subsystem
+	BlkioSubsystem = Subsystem("blkio")
+	//PidsSubsystem pids subsystem
+	PidsSubsystem = Subsystem("pids")
 
 	//CGroupBase base mount point
 	CGroupBase = "/sys/fs/cgroup"
@@ -46,6 +52,16 @@ var (
 		DevicesSubsystem: mkDevicesGroup,
 		CPUSetSubsystem:  mkCPUSetGroup,
 		MemorySubsystem:  mkMemoryGroup,
+		CPUAcctSubsystem: mkAccountingGroup,
+		BlkioSubsystem:   mkAccountingGroup,
+		PidsSubsystem:    mkAccountingGroup,
+	}
+
+	//optional subsystems are only used for accounting, they are dropped if the kernel doesn't support them
+	optional = map[Subsystem]bool{
+		CPUAcctSubsystem: true,
+		BlkioSubsystem:   true,
+		PidsSubsystem:    true,
 	}
 
 	//ErrDoesNotExist does not exist error
@@ -68,7 +84,12 @@ func Init() (err error) {
 			os.MkdirAll(p, 0755)
 
 			err = syscall.Mount(string(sub), p, "cgroup", 0, string(sub))
-			if err != nil {
+			if err != nil && optional[sub] {
+				log.Warningf("cgroup subsystem %s is not available: %s", sub, err)
+				delete(subsystems, sub)
+				os.Remove(p)
+				err = nil
+			} else if err != nil {
 				return
 			}
 		}
@@ -90,6 +111,12 @@ func Init() (err error) {
 	return
 }
 
+//Available checks if a subsystem is mounted
+func Available(subsystem Subsystem) bool {
+	_, ok := subsystems[subsystem]
+	return ok
+}
+
 //GetGroup creaes a group if it does not exist
 func GetGroup(subsystem Subsystem, name string) (Group, error) {
 	mkg, ok := subsystems[subsystem]
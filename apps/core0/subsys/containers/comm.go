@@ -18,6 +18,7 @@ const (
 type Message struct {
 	Type    string          `json:"type"`
 	Command string          `json:"command"`
+	Tags    pm.Tags         `json:"tags"`
 	Payload json.RawMessage `json:"payload"`
 }
 
@@ -66,6 +67,7 @@ func (c *container) rewind() {
 			logger.Current.LogRecord(&logger.LogRecord{
 				Core:    c.id,
 				Command: message.Command,
+				Tags:    message.Tags,
 				Message: &msg,
 			})
 		case "stats":
@@ -40,6 +40,9 @@ type container struct {
 	forwardChan chan *pm.Command
 
 	terminating bool
+
+	//accountingGroups subsystems where the container has its own group
+	accountingGroups []cgroups.Subsystem
 }
 
 func newContainer(mgr *containerManager, id uint16, args ContainerCreateArguments) *container {
@@ -186,6 +189,8 @@ func (c *container) onStart(pid int) {
 		group.Task(pid)
 	}
 
+	c.accounting(pid)
+
 	if err := c.postStart(); err != nil {
 		log.Errorf("container post start error: %s", err)
 		//TODO. Should we shut the container down?
@@ -205,6 +210,7 @@ func (c *container) onExit(state bool) {
 	}
 	logger.Current.LogRecord(&logger.LogRecord{
 		Command: fmt.Sprintf("container.%s", tags),
+		Tags:    c.Args.Tags,
 		Message: &stream.Message{
 			Meta: stream.NewMeta(0, stream.ExitSuccessFlag),
 		},
@@ -222,6 +228,12 @@ func (c *container) cleanup() {
 
 	c.destroyNetwork()
 
+	for _, subsystem := range c.accountingGroups {
+		if err := cgroups.Remove(subsystem, c.cgroupName()); err != nil {
+			log.Errorf("failed to remove container-%d %s cgroup: %s", c.id, subsystem, err)
+		}
+	}
+
 	if err := c.unMountAll(); err != nil {
 		log.Errorf("unmounting container-%d was not clean", err)
 	}
@@ -14,6 +14,7 @@ import (
 	"github.com/op/go-logging"
 	"github.com/pborman/uuid"
 	"github.com/vishvananda/netlink"
+	"github.com/zero-os/0-core/apps/core0/builtin"
 	"github.com/zero-os/0-core/apps/core0/helper/socat"
 	"github.com/zero-os/0-core/apps/core0/screen"
 	"github.com/zero-os/0-core/apps/core0/subsys/cgroups"
@@ -305,6 +306,8 @@ func ContainerSubsystem(sink *transport.Sink, cell *screen.RowCell) (ContainerMa
 	pm.RegisterBuiltIn(cmdContainerZerotierInfo, containerMgr.ztInfo)
 	pm.RegisterBuiltIn(cmdContainerZerotierList, containerMgr.ztList)
 
+	builtin.RegisterMonitor(monitorContainers, containerMgr.monitor)
+
 	return containerMgr, nil
 }
 
@@ -591,7 +594,7 @@ func (m *containerManager) getCoreXQueue(id uint16) string {
 }
 
 func (m *containerManager) pushToContainer(container *container, cmd *pm.Command) error {
-	m.sink.Flag(cmd.ID)
+	m.sink.Flag(cmd)
 	return container.dispatch(cmd)
 }
 
@@ -617,6 +620,9 @@ func (m *containerManager) dispatch(cmd *pm.Command) (interface{}, error) {
 		args.Command.ID = uuid.New()
 	}
 
+	//the container command is run on behalf of the dispatch caller
+	args.Command.Identity = cmd.Identity
+
 	if err := m.pushToContainer(cont, &args.Command); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,92 @@
+package containers
+
+import (
+	"fmt"
+
+	"github.com/zero-os/0-core/apps/core0/subsys/cgroups"
+	"github.com/zero-os/0-core/base/pm"
+)
+
+const (
+	monitorContainers = "containers"
+)
+
+//cgroupName of the container own accounting groups
+func (c *container) cgroupName() string {
+	return fmt.Sprintf("core-%d", c.id)
+}
+
+//accounting adds the container to its own group in all accounting subsystems, except the ones
+//where it was created in a group
+func (c *container) accounting(pid int) {
+	set := make(map[cgroups.Subsystem]bool)
+	for _, cgroup := range c.Args.CGroups {
+		set[cgroup.Subsystem()] = true
+	}
+
+	for _, subsystem := range cgroups.Accounting {
+		if set[subsystem] || !cgroups.Available(subsystem) {
+			continue
+		}
+
+		group, err := cgroups.GetGroup(subsystem, c.cgroupName())
+		if err != nil {
+			log.Errorf("failed to create container-%d %s cgroup: %s", c.id, subsystem, err)
+			continue
+		}
+
+		if err := group.Task(pid); err != nil {
+			log.Errorf("failed to add container-%d to %s cgroup: %s", c.id, subsystem, err)
+			continue
+		}
+
+		c.accountingGroups = append(c.accountingGroups, subsystem)
+	}
+}
+
+//monitor aggregates the cgroups accounting of all containers
+func (m *containerManager) monitor() error {
+	m.conM.RLock()
+	var containers []*container
+	for _, c := range m.containers {
+		if c.PID != 0 {
+			containers = append(containers, c)
+		}
+	}
+	m.conM.RUnlock()
+
+	for _, c := range containers {
+		usage, err := cgroups.ProcessUsage(c.PID)
+		if err != nil {
+			log.Debugf("failed to get container-%d accounting: %s", c.id, err)
+			continue
+		}
+
+		id := fmt.Sprint(c.id)
+		name := pm.Tag{Key: "name", Value: c.Args.Name}
+
+		if cpu := usage.CPU; cpu != nil {
+			//seconds of cpu time per second, like machine.CPU.utilisation
+			pm.Aggregate(pm.AggreagteDifference, "container.CPU.utilisation", float64(cpu.Usage)/1e9, id, name)
+		}
+
+		if memory := usage.Memory; memory != nil {
+			pm.Aggregate(pm.AggreagteAverage, "container.memory.usage", float64(memory.Usage)/(1024.*1024.), id, name)
+			pm.Aggregate(pm.AggreagteAverage, "container.memory.rss", float64(memory.RSS)/(1024.*1024.), id, name)
+			pm.Aggregate(pm.AggreagteAverage, "container.memory.cache", float64(memory.Cache)/(1024.*1024.), id, name)
+		}
+
+		if blkio := usage.Blkio; blkio != nil {
+			pm.Aggregate(pm.AggreagteDifference, "container.disk.throughput.read", float64(blkio.ReadBytes/1024), id, name)
+			pm.Aggregate(pm.AggreagteDifference, "container.disk.throughput.write", float64(blkio.WriteBytes/1024), id, name)
+			pm.Aggregate(pm.AggreagteDifference, "container.disk.iops.read", float64(blkio.ReadOps), id, name)
+			pm.Aggregate(pm.AggreagteDifference, "container.disk.iops.write", float64(blkio.WriteOps), id, name)
+		}
+
+		if pids := usage.Pids; pids != nil {
+			pm.Aggregate(pm.AggreagteAverage, "container.pids", float64(pids.Current), id, name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,360 @@
+package transport
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/pborman/uuid"
+	"github.com/zero-os/0-core/base/auth"
+	"github.com/zero-os/0-core/base/pm"
+	"github.com/zero-os/0-core/base/pm/stream"
+)
+
+const (
+	APIPrefix        = "/api/v1/jobs"
+	APIDefaultListen = ":8443"
+
+	APIDefaultResultTimeout = 10
+	APIMaxResultTimeout     = 60
+	APIStreamBufferSize     = 100
+	//APIMaxResultWaits max number of requests blocking on a job result, it's kept under the
+	//sink WaitPoolSize so the API never holds all the connections other result waiters need
+	APIMaxResultWaits = WaitPoolSize / 2
+
+	//IdentityAnonymous is the identity of callers when no authentication is required
+	IdentityAnonymous = "anonymous"
+
+	apiContainerDispatch = "corex.dispatch"
+)
+
+type APIConfig struct {
+	//Listen address of the HTTPS server
+	Listen string
+	//Cert and Key paths, if not set a self signed certificate is used
+	Cert string
+	Key  string
+	//Auth options, same as the redis proxy. If any backend is configured, callers must
+	//authenticate and can only submit the commands the policy allows them.
+	Auth auth.Options
+}
+
+/*
+API is an HTTPS/JSON interface to the process manager, for clients that can't speak redis. Commands
+submitted over the API are processed exactly like the ones received on the sink command queues.
+
+	POST /api/v1/jobs             submit a command (body is the command json)
+	GET  /api/v1/jobs             list running jobs
+	GET  /api/v1/jobs/<id>        get job result (?timeout=seconds)
+	GET  /api/v1/jobs/<id>/stream stream job output as server-sent events
+*/
+type API struct {
+	sink          *Sink
+	server        *http.Server
+	authenticator *auth.HTTPAuthenticator
+	waits         chan struct{}
+}
+
+//apiCaller is an authenticated caller of the API
+type apiCaller struct {
+	identity string
+	//commands patterns the caller is allowed to run, nil means all commands are allowed
+	commands []string
+}
+
+type apiJob struct {
+	StartTime int64       `json:"starttime"`
+	Cmd       *pm.Command `json:"cmd"`
+}
+
+func NewAPI(sink *Sink, c APIConfig) (*API, error) {
+	api := &API{
+		sink:  sink,
+		waits: make(chan struct{}, APIMaxResultWaits),
+	}
+
+	authenticator, err := auth.NewHTTPAuthenticator(c.Auth)
+	if err != nil {
+		return nil, err
+	}
+	api.authenticator = authenticator
+
+	var config *tls.Config
+	if c.Cert != "" || c.Key != "" {
+		crt, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		config = &tls.Config{Certificates: []tls.Certificate{crt}}
+	} else {
+		if config, err = auth.SelfSignedTLSConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Auth.ClientCA != "" {
+		pool, err := auth.ClientCAPool(c.Auth.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+
+		//clients that don't present a certificate can still authenticate with a bearer token
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		config.ClientCAs = pool
+	}
+
+	if c.Listen == "" {
+		c.Listen = APIDefaultListen
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(APIPrefix, api.authenticated(api.jobs))
+	mux.HandleFunc(APIPrefix+"/", api.authenticated(api.job))
+
+	api.server = &http.Server{
+		Addr:      c.Listen,
+		Handler:   mux,
+		TLSConfig: config,
+	}
+
+	return api, nil
+}
+
+//Start serving the API
+func (a *API) Start() {
+	go func() {
+		log.Infof("Starting API on %s", a.server.Addr)
+		if err := a.server.ListenAndServeTLS("", ""); err != nil {
+			log.Errorf("API server error: %s", err)
+		}
+	}()
+}
+
+func (a *API) write(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Errorf("failed to write API response: %s", err)
+	}
+}
+
+func (a *API) error(w http.ResponseWriter, code int, err error) {
+	a.write(w, code, map[string]string{"error": err.Error()})
+}
+
+func (a *API) authenticated(handler func(http.ResponseWriter, *http.Request, *apiCaller)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller := &apiCaller{identity: IdentityAnonymous}
+		if a.authenticator != nil {
+			identity, commands := a.authenticator.Authenticate(r)
+			if identity == nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				a.error(w, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+				return
+			}
+
+			if len(commands) == 0 {
+				a.error(w, http.StatusForbidden, fmt.Errorf("permission denied, caller is not allowed any command"))
+				return
+			}
+
+			caller = &apiCaller{identity: identity.Name, commands: commands}
+		}
+
+		handler(w, r, caller)
+	}
+}
+
+//authorize checks if the command, and for container dispatches the command that will run
+//inside the container, is allowed for the caller
+func (c *apiCaller) authorize(command *pm.Command) error {
+	if c.commands != nil && !auth.Allowed(c.commands, command.Command) {
+		return fmt.Errorf("permission denied: command '%s' is not allowed", command.Command)
+	}
+
+	if command.Command != apiContainerDispatch {
+		return nil
+	}
+
+	var args struct {
+		Command pm.Command `json:"command"`
+	}
+
+	if command.Arguments != nil {
+		if err := json.Unmarshal(*command.Arguments, &args); err != nil {
+			return fmt.Errorf("invalid %s arguments", apiContainerDispatch)
+		}
+	}
+
+	return c.authorize(&args.Command)
+}
+
+func (a *API) jobs(w http.ResponseWriter, r *http.Request, caller *apiCaller) {
+	switch r.Method {
+	case http.MethodGet:
+		a.list(w, r)
+	case http.MethodPost:
+		a.submit(w, r, caller)
+	default:
+		a.error(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+	}
+}
+
+func (a *API) job(w http.ResponseWriter, r *http.Request, _ *apiCaller) {
+	if r.Method != http.MethodGet {
+		a.error(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, APIPrefix+"/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		a.result(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "stream":
+		a.stream(w, r, parts[0])
+	default:
+		a.error(w, http.StatusNotFound, fmt.Errorf("not found"))
+	}
+}
+
+func (a *API) submit(w http.ResponseWriter, r *http.Request, caller *apiCaller) {
+	var command pm.Command
+	if err := json.NewDecoder(r.Body).Decode(&command); err != nil {
+		a.error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := caller.authorize(&command); err != nil {
+		a.error(w, http.StatusForbidden, err)
+		return
+	}
+
+	command.Identity = caller.identity
+
+	if command.ID == "" {
+		command.ID = uuid.New()
+	}
+
+	err := a.sink.Run(&command)
+	if _, ok := err.(*NotifyError); ok {
+		a.error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	switch err {
+	case nil:
+		a.write(w, http.StatusCreated, command.ID)
+	case pm.UnknownCommandErr:
+		a.error(w, http.StatusBadRequest, err)
+	case pm.DuplicateIDErr:
+		a.error(w, http.StatusConflict, err)
+	default:
+		a.error(w, http.StatusInternalServerError, err)
+	}
+}
+
+func (a *API) list(w http.ResponseWriter, r *http.Request) {
+	jobs := make([]apiJob, 0)
+	for _, job := range pm.Jobs() {
+		jobs = append(jobs, apiJob{
+			StartTime: job.StartTime(),
+			Cmd:       job.Command(),
+		})
+	}
+
+	a.write(w, http.StatusOK, jobs)
+}
+
+func (a *API) result(w http.ResponseWriter, r *http.Request, id string) {
+	timeout := APIDefaultResultTimeout
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		var err error
+		if timeout, err = strconv.Atoi(value); err != nil || timeout <= 0 {
+			a.error(w, http.StatusBadRequest, fmt.Errorf("invalid timeout '%s'", value))
+			return
+		}
+	}
+
+	if timeout > APIMaxResultTimeout {
+		timeout = APIMaxResultTimeout
+	}
+
+	select {
+	case a.waits <- struct{}{}:
+		defer func() { <-a.waits }()
+	default:
+		a.error(w, http.StatusServiceUnavailable, fmt.Errorf("too many requests waiting for results"))
+		return
+	}
+
+	result, err := a.sink.GetResult(id, timeout)
+	if err == redis.ErrNil {
+		a.error(w, http.StatusAccepted, fmt.Errorf("job '%s' is still running", id))
+		return
+	} else if err != nil {
+		a.error(w, http.StatusNotFound, err)
+		return
+	}
+
+	a.write(w, http.StatusOK, result)
+}
+
+func (a *API) stream(w http.ResponseWriter, r *http.Request, id string) {
+	job, ok := pm.JobOf(id)
+	if !ok {
+		a.error(w, http.StatusNotFound, fmt.Errorf("job '%s' is not running", id))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		a.error(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	messages := make(chan *stream.Message, APIStreamBufferSize)
+	subscription := job.Subscribe(func(msg *stream.Message) {
+		select {
+		case messages <- msg:
+		default:
+			//never block the job on a slow client
+		}
+	})
+	defer job.Unsubscribe(subscription)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	event := func(name string, body interface{}) {
+		data, err := json.Marshal(body)
+		if err != nil {
+			log.Errorf("failed to marshal API event: %s", err)
+			return
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+		flusher.Flush()
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-messages:
+			event("message", msg)
+		case <-job.Done():
+			//flush what is left of the output before sending the result
+			for len(messages) > 0 {
+				event("message", <-messages)
+			}
+			event("result", job.Wait())
+			return
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/auth"
+	"github.com/zero-os/0-core/base/pm"
+	"github.com/zero-os/0-core/base/pm/stream"
+)
+
+const testStreamCommand = "test.api.stream"
+
+//testStreamRelease is closed to let the stream test command exit
+var testStreamRelease chan struct{}
+
+func init() {
+	pm.RegisterBuiltInWithCtx(testStreamCommand, func(ctx *pm.Context) (interface{}, error) {
+		release := testStreamRelease
+		ctx.Log("streamed message", stream.LevelStdout)
+		<-release
+		return "streamed", nil
+	})
+}
+
+func testAPI(t *testing.T, config APIConfig) (*API, *httptest.Server) {
+	sink, err := NewSink(SinkConfig{})
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	api, err := NewAPI(sink, config)
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	return api, httptest.NewServer(api.server.Handler)
+}
+
+func submit(t *testing.T, server *httptest.Server, cmd pm.Command) (int, string) {
+	return submitAs(t, server, "", cmd)
+}
+
+func submitAs(t *testing.T, server *httptest.Server, token string, cmd pm.Command) (int, string) {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+APIPrefix, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var id string
+	json.NewDecoder(response.Body).Decode(&id)
+	return response.StatusCode, id
+}
+
+func TestAPISubmitResult(t *testing.T) {
+	fake := newFakeRedis()
+	defer fake.use()()
+
+	_, server := testAPI(t, APIConfig{})
+	defer server.Close()
+
+	code, id := submit(t, server, pm.Command{Command: testSinkCommand, Arguments: pm.MustArguments(nil)})
+	if !assert.Equal(t, http.StatusCreated, code) {
+		t.Fatal()
+	}
+	assert.NotEmpty(t, id)
+
+	response, err := http.Get(server.URL + APIPrefix + "/" + id + "?timeout=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var result pm.JobResult
+	if assert.Equal(t, http.StatusOK, response.StatusCode) && assert.NoError(t, json.NewDecoder(response.Body).Decode(&result)) {
+		assert.Equal(t, id, result.ID)
+		assert.Equal(t, pm.StateSuccess, result.State)
+	}
+}
+
+func TestAPISubmitErrors(t *testing.T) {
+	fake := newFakeRedis()
+	defer fake.use()()
+
+	_, server := testAPI(t, APIConfig{})
+	defer server.Close()
+
+	code, _ := submit(t, server, pm.Command{Command: "test.api.unknown", Arguments: pm.MustArguments(nil)})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = submit(t, server, pm.Command{ID: "api-duplicate", Command: testSinkCommand, Arguments: pm.MustArguments(nil)})
+	assert.Equal(t, http.StatusCreated, code)
+
+	code, _ = submit(t, server, pm.Command{ID: "api-duplicate", Command: testSinkCommand, Arguments: pm.MustArguments(nil)})
+	assert.Equal(t, http.StatusConflict, code)
+
+	code, _ = submit(t, server, pm.Command{Command: testSinkCommand, Arguments: pm.MustArguments(nil), Notify: "core:default"})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	response, err := http.Get(server.URL + APIPrefix + "/api-unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestAPIAuthorization(t *testing.T) {
+	fake := newFakeRedis()
+	defer fake.use()()
+
+	dir, err := ioutil.TempDir("", "api")
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	defer os.RemoveAll(dir)
+
+	tokens := path.Join(dir, "tokens")
+	policy := path.Join(dir, "policy.toml")
+	ioutil.WriteFile(tokens, []byte("secret-1 admin\nsecret-2 monitor monitoring\nsecret-3 other other\n"), 0600)
+	ioutil.WriteFile(policy, []byte("[role.admin]\nscopes = [\"zero-os:admin\"]\ncommands = [\"*\"]\n"+
+		"[role.monitoring]\nscopes = [\"monitoring\"]\ncommands = [\"test.sink\", \"corex.dispatch\"]\n"), 0600)
+
+	_, server := testAPI(t, APIConfig{Auth: auth.Options{Tokens: tokens, Policy: policy}})
+	defer server.Close()
+
+	dispatch := func(command string) pm.Command {
+		return pm.Command{
+			Command: apiContainerDispatch,
+			Arguments: pm.MustArguments(pm.M{
+				"container": 1,
+				"command":   pm.Command{Command: command},
+			}),
+		}
+	}
+
+	cmd := pm.Command{Command: testSinkCommand, Arguments: pm.MustArguments(nil)}
+
+	code, _ := submitAs(t, server, "", cmd)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = submitAs(t, server, "invalid", cmd)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = submitAs(t, server, "secret-3", cmd)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, _ = submitAs(t, server, "secret-2", cmd)
+	assert.Equal(t, http.StatusCreated, code)
+
+	code, _ = submitAs(t, server, "secret-2", pm.Command{Command: "core.system", Arguments: pm.MustArguments(nil)})
+	assert.Equal(t, http.StatusForbidden, code)
+
+	//the command dispatched to the container must be allowed too
+	code, _ = submitAs(t, server, "secret-2", dispatch("core.system"))
+	assert.Equal(t, http.StatusForbidden, code)
+
+	//allowed, but corex.dispatch is not registered in the tests
+	code, _ = submitAs(t, server, "secret-2", dispatch(testSinkCommand))
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = submitAs(t, server, "secret-1", dispatch("core.system"))
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestAPIResultWaits(t *testing.T) {
+	fake := newFakeRedis()
+	defer fake.use()()
+
+	api, server := testAPI(t, APIConfig{})
+	defer server.Close()
+
+	//all the waiting slots are taken by other requests
+	for i := 0; i < APIMaxResultWaits; i++ {
+		api.waits <- struct{}{}
+	}
+
+	response, err := http.Get(server.URL + APIPrefix + "/api-waits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+
+	for i := 0; i < APIMaxResultWaits; i++ {
+		<-api.waits
+	}
+
+	//the API never takes all the connections of the wait pool
+	assert.True(t, APIMaxResultWaits < WaitPoolSize)
+}
+
+func TestAPIStream(t *testing.T) {
+	fake := newFakeRedis()
+	defer fake.use()()
+
+	_, server := testAPI(t, APIConfig{})
+	defer server.Close()
+
+	testStreamRelease = make(chan struct{})
+	code, id := submit(t, server, pm.Command{Command: testStreamCommand, Arguments: pm.MustArguments(nil)})
+	if !assert.Equal(t, http.StatusCreated, code) {
+		t.Fatal()
+	}
+
+	//the job is started asynchronously
+	deadline := time.Now().Add(5 * time.Second)
+	for _, ok := pm.JobOf(id); !ok && time.Now().Before(deadline); _, ok = pm.JobOf(id) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	response, err := http.Get(server.URL + APIPrefix + "/" + id + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if !assert.Equal(t, http.StatusOK, response.StatusCode) {
+		t.Fatal()
+	}
+
+	events := make(chan string)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "event: ") {
+				events <- strings.TrimPrefix(line, "event: ")
+			}
+		}
+	}()
+
+	next := func() string {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for stream event")
+		}
+		return ""
+	}
+
+	assert.Equal(t, "message", next())
+
+	close(testStreamRelease)
+	for event := next(); event != "result"; event = next() {
+		assert.Equal(t, "message", event)
+	}
+
+	//the stream is closed after the result
+	_, ok := <-events
+	assert.False(t, ok)
+}
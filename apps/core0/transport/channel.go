@@ -18,15 +18,28 @@ ControllerClient represents an active agent controller connection.
 */
 type channel struct {
 	pool *redis.Pool
+	//workers pool is only used by the workers blocking on the command queues, so they never hold
+	//connections of the main pool
+	workers *redis.Pool
+	//waits pool is only used to block on job results
+	waits *redis.Pool
+	ttl   int
 }
 
 /*
 NewSinkClient gets a new sink connection with the given identity. Identity is used by the sink client to
 introduce itself to the sink terminal.
 */
-func newChannel(pool *redis.Pool) *channel {
+func newChannel(pool, workers, waits *redis.Pool, ttl int) *channel {
+	if ttl <= 0 {
+		ttl = ReturnExpire
+	}
+
 	ch := &channel{
-		pool: pool,
+		pool:    pool,
+		workers: workers,
+		waits:   waits,
+		ttl:     ttl,
 	}
 
 	return ch
@@ -36,24 +49,40 @@ func (cl *channel) String() string {
 	return "redis"
 }
 
-//GetNext gets the next available command
-func (cl *channel) GetNext(queue string, command *pm.Command) error {
-	conn := cl.pool.Get()
+//GetNext gets the next available command from the first non empty queue, queues
+//are checked in the given order. It returns the name of the queue the command was
+//received on
+func (cl *channel) GetNext(queues []string, command *pm.Command) (string, error) {
+	conn := cl.workers.Get()
 	defer conn.Close()
 
-	payload, err := redis.ByteSlices(conn.Do("BLPOP", queue, 10))
+	args := make([]interface{}, 0, len(queues)+1)
+	for _, queue := range queues {
+		args = append(args, queue)
+	}
+	args = append(args, 10)
+
+	payload, err := redis.ByteSlices(conn.Do("BLPOP", args...))
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	if payload == nil || len(payload) < 2 {
-		return redis.ErrNil
+		return "", redis.ErrNil
 	}
 
-	return json.Unmarshal(payload[1], command)
+	return string(payload[0]), json.Unmarshal(payload[1], command)
+}
+
+//Len gets the number of pending commands in a queue
+func (cl *channel) Len(queue string) (int64, error) {
+	conn := cl.pool.Get()
+	defer conn.Close()
+
+	return redis.Int64(conn.Do("LLEN", queue))
 }
 
-func (cl *channel) Respond(result *pm.JobResult) error {
+func (cl *channel) Respond(result *pm.JobResult, ttl int) error {
 	if result.ID == "" {
 		return fmt.Errorf("result with no ID, not pushing results back")
 	}
@@ -67,7 +96,7 @@ func (cl *channel) Respond(result *pm.JobResult) error {
 	conn := cl.pool.Get()
 	defer conn.Close()
 
-	if _, err := conn.Do("EXPIRE", queue, ReturnExpire); err != nil {
+	if _, err := conn.Do("EXPIRE", queue, ttl); err != nil {
 		return err
 	}
 
@@ -91,7 +120,7 @@ func (cl *channel) Push(queue string, payload interface{}) error {
 }
 
 func (cl *channel) cycle(queue string, timeout int) ([]byte, error) {
-	conn := cl.pool.Get()
+	conn := cl.waits.Get()
 	defer conn.Close()
 
 	return redis.Bytes(conn.Do("BRPOPLPUSH", queue, queue, timeout))
@@ -112,21 +141,55 @@ func (cl *channel) GetResponse(id string, timeout int) (*pm.JobResult, error) {
 	return &result, nil
 }
 
-func (cl *channel) Flag(id string) error {
+//Flag marks the job as running, the flag holds the job result ttl (in seconds) and its notify
+//target if any
+func (cl *channel) Flag(id string, ttl int, notify string) error {
 	conn := cl.pool.Get()
 	defer conn.Close()
 
-	key := fmt.Sprintf("result:%s:flag", id)
-	_, err := conn.Do("RPUSH", key, "")
+	if ttl <= 0 {
+		ttl = cl.ttl
+	}
+
+	args := []interface{}{fmt.Sprintf("result:%s:flag", id), ttl}
+	if len(notify) != 0 {
+		args = append(args, notify)
+	}
+
+	_, err := conn.Do("RPUSH", args...)
 	return err
 }
 
-func (cl *channel) UnFlag(id string) error {
+//TTL gets the result ttl of the job as set on its flag, or the default ttl
+func (cl *channel) TTL(id string) int {
+	conn := cl.pool.Get()
+	defer conn.Close()
+
+	key := fmt.Sprintf("result:%s:flag", id)
+	ttl, err := redis.Int(conn.Do("LINDEX", key, 0))
+	if err != nil || ttl <= 0 {
+		return cl.ttl
+	}
+
+	return ttl
+}
+
+//Notify gets the notify target of the job as set on its flag
+func (cl *channel) Notify(id string) string {
+	conn := cl.pool.Get()
+	defer conn.Close()
+
+	key := fmt.Sprintf("result:%s:flag", id)
+	notify, _ := redis.String(conn.Do("LINDEX", key, 1))
+	return notify
+}
+
+func (cl *channel) UnFlag(id string, ttl int) error {
 	conn := cl.pool.Get()
 	defer conn.Close()
 
 	key := fmt.Sprintf("result:%s:flag", id)
-	_, err := conn.Do("EXPIRE", key, ReturnExpire)
+	_, err := conn.Do("EXPIRE", key, ttl)
 	return err
 }
 
@@ -0,0 +1,136 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zero-os/0-core/base/pm"
+)
+
+const (
+	NotifyRetries    = 5
+	NotifyRetryDelay = 2 * time.Second
+	NotifyTimeout    = 10 * time.Second
+)
+
+//reserved redis queues prefixes, a notification is never pushed to any of them
+//otherwise a result could be consumed as a new command.
+var notifyReservedPrefixes = []string{"core:", "result:", "corex:"}
+
+/*
+notifier sends the job results to the job `notify` target, so clients don't have to poll for
+results. The target is either an http(s) URL the result is POSTed to or a redis queue the result
+is pushed to. The target is kept on the job flag, so results forwarded by the sink on behalf of
+the containers are notified as well.
+*/
+type notifier struct {
+	sink   *Sink
+	client *http.Client
+}
+
+func newNotifier(sink *Sink) *notifier {
+	return &notifier{
+		sink: sink,
+		client: &http.Client{
+			Timeout: NotifyTimeout,
+		},
+	}
+}
+
+//Notify sends the result to the target in the background
+func (n *notifier) Notify(target string, result *pm.JobResult) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		log.Errorf("failed to marshal result of job %s: %s", result.ID, err)
+		return
+	}
+
+	//notifications must never block the result forwarding
+	go func() {
+		if err := n.notify(target, data); err != nil {
+			log.Errorf("failed to notify '%s' with result of job %s: %s", target, result.ID, err)
+		}
+	}()
+}
+
+//validateNotify checks a notify target, so commands with a bad target are rejected when they
+//are submitted instead of failing in the background on each notification
+func validateNotify(target string) error {
+	if strings.Contains(target, "://") {
+		u, err := url.Parse(target)
+		if err != nil {
+			return fmt.Errorf("invalid notify url '%s': %s", target, err)
+		}
+
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid notify url '%s': scheme must be http or https", target)
+		}
+
+		if u.Host == "" {
+			return fmt.Errorf("invalid notify url '%s': no host", target)
+		}
+
+		return nil
+	}
+
+	for _, prefix := range notifyReservedPrefixes {
+		if strings.HasPrefix(target, prefix) {
+			return fmt.Errorf("notify queue can't start with '%s'", prefix)
+		}
+	}
+
+	return nil
+}
+
+func (n *notifier) notify(target string, data []byte) error {
+	if err := validateNotify(target); err != nil {
+		return err
+	}
+
+	var send func() error
+	if strings.Contains(target, "://") {
+		send = func() error {
+			return n.post(target, data)
+		}
+	} else {
+		send = func() error {
+			_, err := n.sink.RPush(target, data)
+			return err
+		}
+	}
+
+	var err error
+	delay := NotifyRetryDelay
+	for i := 0; i < NotifyRetries; i++ {
+		if err = send(); err == nil {
+			return nil
+		}
+
+		log.Warningf("notify '%s' failed (attempt %d/%d): %s", target, i+1, NotifyRetries, err)
+		if i < NotifyRetries-1 {
+			<-time.After(delay)
+			delay *= 2
+		}
+	}
+
+	return err
+}
+
+func (n *notifier) post(url string, data []byte) error {
+	response, err := n.client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status: %s", response.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package transport
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/pm"
+)
+
+//waitNotification waits for a result to be pushed to the notify queue
+func waitNotification(t *testing.T, fake *fakeRedis, queue string) *pm.JobResult {
+	deadline := time.Now().Add(5 * time.Second)
+	for fake.len(queue) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	fake.m.Lock()
+	defer fake.m.Unlock()
+	if len(fake.lists[queue]) == 0 {
+		t.Fatalf("no notification on queue %s", queue)
+	}
+
+	var result pm.JobResult
+	if err := json.Unmarshal(fake.lists[queue][0], &result); err != nil {
+		t.Fatal(err)
+	}
+
+	return &result
+}
+
+func TestNotifyJob(t *testing.T) {
+	fake := newFakeRedis()
+	defer fake.use()()
+
+	sink, err := NewSink(SinkConfig{})
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	err = sink.Run(&pm.Command{ID: "notify-job", Command: testSinkCommand, Arguments: pm.MustArguments(nil), Notify: "test:notify-job"})
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	result := waitNotification(t, fake, "test:notify-job")
+	assert.Equal(t, "notify-job", result.ID)
+	assert.Equal(t, pm.StateSuccess, result.State)
+}
+
+func TestNotifyRecurring(t *testing.T) {
+	fake := newFakeRedis()
+	defer fake.use()()
+
+	sink, err := NewSink(SinkConfig{})
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	err = sink.Run(&pm.Command{ID: "notify-recurring", Command: testSinkCommand, Arguments: pm.MustArguments(nil), RecurringPeriod: 1, Notify: "test:notify-recurring"})
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	defer pm.Kill("notify-recurring")
+
+	//each run of the recurring job is notified, before the job exits
+	deadline := time.Now().Add(5 * time.Second)
+	for fake.len("test:notify-recurring") < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.True(t, fake.len("test:notify-recurring") >= 2)
+
+	_, running := pm.JobOf("notify-recurring")
+	assert.True(t, running)
+}
+
+func TestNotifyInvalidTarget(t *testing.T) {
+	fake := newFakeRedis()
+	defer fake.use()()
+
+	sink, err := NewSink(SinkConfig{})
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	err = sink.Run(&pm.Command{ID: "notify-invalid", Command: testSinkCommand, Arguments: pm.MustArguments(nil), Notify: "ftp://example.com"})
+	if _, ok := err.(*NotifyError); !assert.True(t, ok) {
+		t.Fatal()
+	}
+
+	//the job never runs, the client gets the error as the job result
+	result, err := sink.GetResult("notify-invalid", 5)
+	if assert.NoError(t, err) {
+		assert.Equal(t, pm.StateError, result.State)
+	}
+
+	_, running := pm.JobOf("notify-invalid")
+	assert.False(t, running)
+}
+
+func TestValidateNotify(t *testing.T) {
+	assert.NoError(t, validateNotify(""))
+	assert.NoError(t, validateNotify("http://example.com/results"))
+	assert.NoError(t, validateNotify("https://example.com/results"))
+	assert.NoError(t, validateNotify("myapp:results"))
+
+	assert.Error(t, validateNotify("ftp://example.com/results"))
+	assert.Error(t, validateNotify("http:///results"))
+	assert.Error(t, validateNotify(SinkQueue))
+	assert.Error(t, validateNotify("result:notify"))
+	assert.Error(t, validateNotify("corex:1"))
+}
+
+func TestNotifyUnknownCommand(t *testing.T) {
+	fake := newFakeRedis()
+	defer fake.use()()
+
+	sink, err := NewSink(SinkConfig{})
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	err = sink.Run(&pm.Command{ID: "notify-unknown", Command: "test.notify.unknown", Arguments: pm.MustArguments(nil), Notify: "test:notify-unknown"})
+	assert.Equal(t, pm.UnknownCommandErr, err)
+
+	result := waitNotification(t, fake, "test:notify-unknown")
+	assert.Equal(t, pm.StateUnknownCmd, result.State)
+}
+
+func TestNotifyContainerResult(t *testing.T) {
+	fake := newFakeRedis()
+	defer fake.use()()
+
+	sink, err := NewSink(SinkConfig{})
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	//container commands are flagged by core0, their results are forwarded once received
+	//from the container
+	if !assert.NoError(t, sink.Flag(&pm.Command{ID: "notify-container", Notify: "test:notify-container"})) {
+		t.Fatal()
+	}
+
+	if !assert.NoError(t, sink.Forward(&pm.JobResult{ID: "notify-container", State: pm.StateSuccess, Container: 1})) {
+		t.Fatal()
+	}
+
+	result := waitNotification(t, fake, "test:notify-container")
+	assert.Equal(t, uint64(1), result.Container)
+}
+
+func TestNotifyReservedQueue(t *testing.T) {
+	var n notifier
+	assert.Error(t, n.notify(SinkQueue, []byte("{}")))
+	assert.Error(t, n.notify("result:notify", []byte("{}")))
+}
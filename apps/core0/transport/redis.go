@@ -6,16 +6,29 @@ import (
 	"github.com/garyburd/redigo/redis"
 )
 
-func newPool() *redis.Pool {
+const (
+	//PoolSize max number of connections used for the short sink requests (push, flags, results)
+	PoolSize = 20
+	//WaitPoolSize max number of connections blocking on job results, waiting callers queue on
+	//this pool instead of holding connections of the main pool
+	WaitPoolSize = 10
+)
+
+//dial connects to the local redis
+var dial = func() (redis.Conn, error) {
+	return redis.Dial("unix", "/var/run/redis.sock")
+}
+
+func newPool(size int) *redis.Pool {
+	dial := dial
 	return &redis.Pool{
-		Dial: func() (redis.Conn, error) {
-			return redis.Dial("unix", "/var/run/redis.sock")
-		},
+		Dial: dial,
 		TestOnBorrow: func(c redis.Conn, t time.Time) error {
 			_, err := c.Do("PING")
 			return err
 		},
-		MaxActive: 20,
+		MaxActive: size,
+		MaxIdle:   size,
 		Wait:      true,
 	}
 }
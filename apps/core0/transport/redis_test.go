@@ -0,0 +1,210 @@
+package transport
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+//fakeRedis is an in memory redis that supports the commands used by the sink
+type fakeRedis struct {
+	lists  map[string][][]byte
+	values map[string][]byte
+	m      sync.Mutex
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{
+		lists:  make(map[string][][]byte),
+		values: make(map[string][]byte),
+	}
+}
+
+//use makes the pools created after the call connect to the fake redis
+func (f *fakeRedis) use() func() {
+	original := dial
+	dial = func() (redis.Conn, error) {
+		return &fakeConn{redis: f}, nil
+	}
+
+	return func() {
+		dial = original
+	}
+}
+
+func (f *fakeRedis) push(key string, values ...[]byte) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	f.lists[key] = append(f.lists[key], values...)
+}
+
+func (f *fakeRedis) len(key string) int {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	return len(f.lists[key])
+}
+
+func bytesOf(arg interface{}) []byte {
+	switch arg := arg.(type) {
+	case []byte:
+		return arg
+	case string:
+		return []byte(arg)
+	default:
+		return []byte(fmt.Sprint(arg))
+	}
+}
+
+func intOf(arg interface{}) int {
+	v, _ := strconv.Atoi(string(bytesOf(arg)))
+	return v
+}
+
+//pop waits for the first non empty list, the lock is held on return
+func (f *fakeRedis) pop(keys []interface{}, timeout int, left bool) (string, []byte) {
+	deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+	for {
+		f.m.Lock()
+		for _, key := range keys {
+			name := string(bytesOf(key))
+			list := f.lists[name]
+			if len(list) == 0 {
+				continue
+			}
+
+			var value []byte
+			if left {
+				value, f.lists[name] = list[0], list[1:]
+			} else {
+				value, f.lists[name] = list[len(list)-1], list[:len(list)-1]
+			}
+
+			return name, value
+		}
+
+		if time.Now().After(deadline) {
+			return "", nil
+		}
+
+		f.m.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (f *fakeRedis) do(cmd string, args ...interface{}) (interface{}, error) {
+	switch strings.ToUpper(cmd) {
+	case "BLPOP":
+		key, value := f.pop(args[:len(args)-1], intOf(args[len(args)-1]), true)
+		defer f.m.Unlock()
+		if value == nil {
+			return nil, nil
+		}
+
+		return []interface{}{[]byte(key), value}, nil
+	case "BRPOPLPUSH":
+		_, value := f.pop(args[:1], intOf(args[2]), false)
+		defer f.m.Unlock()
+		if value == nil {
+			return nil, nil
+		}
+
+		dst := string(bytesOf(args[1]))
+		f.lists[dst] = append([][]byte{value}, f.lists[dst]...)
+		return value, nil
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	key := ""
+	if len(args) != 0 {
+		key = string(bytesOf(args[0]))
+	}
+
+	switch strings.ToUpper(cmd) {
+	case "":
+		//flush, sent by the pool when a connection is released
+		return nil, nil
+	case "PING":
+		return "PONG", nil
+	case "RPUSH":
+		for _, arg := range args[1:] {
+			f.lists[key] = append(f.lists[key], bytesOf(arg))
+		}
+		return int64(len(f.lists[key])), nil
+	case "LLEN":
+		return int64(len(f.lists[key])), nil
+	case "LINDEX":
+		list := f.lists[key]
+		index := intOf(args[1])
+		if index >= len(list) {
+			return nil, nil
+		}
+		return list[index], nil
+	case "LTRIM":
+		return "OK", nil
+	case "EXPIRE":
+		return int64(1), nil
+	case "EXISTS":
+		_, value := f.values[key]
+		if len(f.lists[key]) != 0 || value {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case "GET":
+		if value, ok := f.values[key]; ok {
+			return value, nil
+		}
+		return nil, nil
+	case "SET":
+		f.values[key] = bytesOf(args[1])
+		return "OK", nil
+	case "DEL":
+		var count int64
+		for _, arg := range args {
+			name := string(bytesOf(arg))
+			if _, ok := f.values[name]; ok {
+				count++
+			}
+			delete(f.values, name)
+			delete(f.lists, name)
+		}
+		return count, nil
+	}
+
+	return nil, fmt.Errorf("unsupported command %s", cmd)
+}
+
+type fakeConn struct {
+	redis *fakeRedis
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Err() error {
+	return nil
+}
+
+func (c *fakeConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return c.redis.do(cmd, args...)
+}
+
+func (c *fakeConn) Send(cmd string, args ...interface{}) error {
+	return fmt.Errorf("not supported")
+}
+
+func (c *fakeConn) Flush() error {
+	return nil
+}
+
+func (c *fakeConn) Receive() (interface{}, error) {
+	return nil, fmt.Errorf("not supported")
+}
@@ -1,36 +1,133 @@
 package transport
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/garyburd/redigo/redis"
 	"github.com/zero-os/0-core/base/pm"
+	"github.com/zero-os/0-core/base/settings"
 )
 
 const (
-	SinkQueue = "core:default"
-	DBIndex   = 0
+	SinkQueue       = "core:default"
+	SinkQueueUrgent = "core:urgent"
+	SinkQueueBulk   = "core:bulk"
+	DBIndex         = 0
+
+	DefaultSinkWorkers = 1
+	//MaxSinkWorkers max number of workers, each worker holds its own connection of the workers pool
+	//while blocking on the queues, so they never starve the other sink users from connections.
+	MaxSinkWorkers = 10
+
+	//IdentityRedis identifies commands pushed to the local redis directly
+	IdentityRedis = "redis"
+
+	QueueDepthKey      = "core.queue.depth"
+	QueueDepthInterval = 30 * time.Second
+)
+
+var (
+	ErrNoID = errors.New("command has no ID")
+
+	//DefaultSinkQueues used if no queues are configured
+	DefaultSinkQueues = []settings.SinkQueue{
+		{Name: SinkQueueUrgent, Priority: 10},
+		{Name: SinkQueue, Priority: 5},
+		{Name: SinkQueueBulk, Priority: 0},
+	}
 )
 
+//NotifyError is returned by Run if the command notify target is not valid
+type NotifyError struct {
+	error
+}
+
 type Sink struct {
-	ch   *channel
-	pool *redis.Pool
+	ch       *channel
+	pool     *redis.Pool
+	queues   []string
+	workers  int
+	store    *resultStore
+	notifier *notifier
 }
 
 type SinkConfig struct {
 	Port int
+	//Workers number of go routines that consume the command queues
+	Workers int
+	//Queues the command queues to consume, queues with higher priority are served first
+	Queues []settings.SinkQueue
+	//ResultTTL default time (in seconds) job results are kept, unless the command sets its own
+	ResultTTL int
+	//ResultStore if set, job results are also persisted to this directory
+	ResultStore string
 }
 
 func (c *SinkConfig) Local() string {
 	return fmt.Sprintf("127.0.0.1:%d", c.Port)
 }
 
+//queues returns the queue names sorted by priority (highest first)
+func (c *SinkConfig) queues() []string {
+	queues := c.Queues
+	if len(queues) == 0 {
+		queues = DefaultSinkQueues
+	}
+
+	sorted := make([]settings.SinkQueue, len(queues))
+	copy(sorted, queues)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority > sorted[j].Priority
+	})
+
+	var names []string
+	seen := make(map[string]struct{})
+	for _, queue := range sorted {
+		if _, ok := seen[queue.Name]; ok || queue.Name == "" {
+			continue
+		}
+		seen[queue.Name] = struct{}{}
+		names = append(names, queue.Name)
+	}
+
+	return names
+}
+
+func (c *SinkConfig) workers() int {
+	if c.Workers <= 0 {
+		return DefaultSinkWorkers
+	}
+
+	if c.Workers > MaxSinkWorkers {
+		log.Warningf("sink workers limited to %d", MaxSinkWorkers)
+		return MaxSinkWorkers
+	}
+
+	return c.Workers
+}
+
 func NewSink(c SinkConfig) (*Sink, error) {
-	pool := newPool()
+	pool := newPool(PoolSize)
+	workers := c.workers()
 	sink := &Sink{
-		pool: newPool(),
-		ch:   newChannel(pool),
+		pool:    pool,
+		ch:      newChannel(pool, newPool(workers), newPool(WaitPoolSize), c.ResultTTL),
+		queues:  c.queues(),
+		workers: workers,
+	}
+	sink.notifier = newNotifier(sink)
+
+	if len(c.ResultStore) != 0 {
+		//results persistence is optional, the sink can still serve without it
+		store, err := newResultStore(c.ResultStore)
+		if err != nil {
+			log.Errorf("failed to initialize result store, results will not be persisted: %s", err)
+		} else {
+			sink.store = store
+		}
 	}
 
 	pm.AddHandle(sink)
@@ -60,6 +157,22 @@ func (sink *Sink) LTrim(key string, start, stop int64) error {
 	return err
 }
 
+//LRange gets the elements of a list between start and stop
+func (sink *Sink) LRange(key string, start, stop int64) ([][]byte, error) {
+	conn := sink.pool.Get()
+	defer conn.Close()
+
+	return redis.ByteSlices(conn.Do("LRANGE", key, start, stop))
+}
+
+//LLen gets the length of a list
+func (sink *Sink) LLen(key string) (int64, error) {
+	conn := sink.pool.Get()
+	defer conn.Close()
+
+	return redis.Int64(conn.Do("LLEN", key))
+}
+
 //Get gets value from key
 func (sink *Sink) Get(key string) ([]byte, error) {
 	conn := sink.pool.Get()
@@ -107,57 +220,161 @@ func (sink *Sink) Result(cmd *pm.Command, result *pm.JobResult) {
 	}
 }
 
-func (sink *Sink) process() {
+//Exit handler implementation, the result of each run of recurring and restarted jobs is sent to
+//the job notify target, the final result is notified when it's forwarded.
+func (sink *Sink) Exit(cmd *pm.Command, result *pm.JobResult) {
+	if target := sink.ch.Notify(result.ID); len(target) != 0 {
+		sink.notifier.Notify(target, result)
+	}
+}
+
+//Run flags and starts a command, the same way commands received on the command queues are
+//processed.
+func (sink *Sink) Run(command *pm.Command) error {
+	if command.ID == "" {
+		return ErrNoID
+	}
+
+	if sink.ch.Flagged(command.ID) {
+		return pm.DuplicateIDErr
+	}
+
+	if err := validateNotify(command.Notify); err != nil {
+		//the job never runs, the error is the job result
+		sink.ch.Flag(command.ID, command.ResultTTL, "")
+		result := pm.NewJobResult(command)
+		result.State = pm.StateError
+		result.Data = err.Error()
+		sink.Forward(result)
 
+		return &NotifyError{err}
+	}
+
+	if command.Identity == "" {
+		//the command was pushed directly to redis, not through the redis proxy
+		command.Identity = IdentityRedis
+	}
+
+	sink.ch.Flag(command.ID, command.ResultTTL, command.Notify)
+	log.Debugf("Starting command %s", command)
+
+	_, err := pm.Run(command)
+
+	if err == pm.UnknownCommandErr {
+		result := pm.NewJobResult(command)
+		result.State = pm.StateUnknownCmd
+		sink.Forward(result)
+	}
+
+	return err
+}
+
+func (sink *Sink) process() {
 	for {
 		var command pm.Command
-		err := sink.ch.GetNext(SinkQueue, &command)
+		queue, err := sink.ch.GetNext(sink.queues, &command)
 		if err == redis.ErrNil {
 			continue
 		} else if err != nil {
-			log.Errorf("Failed to get next command from (%s): %s", SinkQueue, err)
+			log.Errorf("Failed to get next command from (%v): %s", sink.queues, err)
 			<-time.After(200 * time.Millisecond)
 			continue
 		}
 
-		if command.ID == "" {
+		log.Debugf("Received command %s from queue %s", &command, queue)
+
+		switch err := sink.Run(&command); err {
+		case nil, pm.UnknownCommandErr:
+		case ErrNoID:
 			log.Warningf("receiving a command with no ID, dropping")
-			continue
-		}
-		if sink.ch.Flagged(command.ID) {
+		case pm.DuplicateIDErr:
 			log.Errorf("received a command with a duplicate ID(%v), dropping", command.ID)
+		default:
+			if _, ok := err.(*NotifyError); ok {
+				log.Warningf("command (%s) rejected: %s", command, err)
+				continue
+			}
+			log.Errorf("Unknown error while processing command (%s): %s", command, err)
+		}
+	}
+}
+
+//monitor reports the depth of each of the command queues as stats
+func (sink *Sink) monitor() {
+	for {
+		<-time.After(QueueDepthInterval)
+		sink.depth()
+	}
+}
+
+func (sink *Sink) depth() {
+	for _, queue := range sink.queues {
+		depth, err := sink.ch.Len(queue)
+		if err != nil {
+			log.Errorf("failed to get depth of queue %s: %s", queue, err)
 			continue
 		}
 
-		sink.ch.Flag(command.ID)
-		log.Debugf("Starting command %s", &command)
+		pm.Aggregate(pm.AggreagteAverage, QueueDepthKey, float64(depth), queue)
+	}
+}
 
-		_, err = pm.Run(&command)
+// Forward forwards job result, and sends it to the job notify target. All results go through
+// here, including the ones received from the containers.
+func (sink *Sink) Forward(result *pm.JobResult) error {
+	if target := sink.ch.Notify(result.ID); len(target) != 0 {
+		sink.notifier.Notify(target, result)
+	}
 
-		if err == pm.UnknownCommandErr {
-			result := pm.NewJobResult(&command)
-			result.State = pm.StateUnknownCmd
-			sink.Forward(result)
-		} else if err != nil {
-			log.Errorf("Unknown error while processing command (%s): %s", command, err)
+	ttl := sink.ch.TTL(result.ID)
+	if sink.store != nil {
+		if err := sink.store.Set(result, ttl); err != nil {
+			log.Errorf("failed to store result of job %s: %s", result.ID, err)
 		}
 	}
+
+	sink.ch.UnFlag(result.ID, ttl)
+	return sink.ch.Respond(result, ttl)
 }
 
-//Forward forwards job result
-func (sink *Sink) Forward(result *pm.JobResult) error {
-	sink.ch.UnFlag(result.ID)
-	return sink.ch.Respond(result)
+//Flag marks a job as running
+func (sink *Sink) Flag(cmd *pm.Command) error {
+	return sink.ch.Flag(cmd.ID, cmd.ResultTTL, cmd.Notify)
 }
 
-//Flag marks a job ID as running
-func (sink *Sink) Flag(id string) error {
-	return sink.ch.Flag(id)
+//restore pushes the stored results that has not expired yet back to redis
+func (sink *Sink) restore() {
+	if sink.store == nil {
+		return
+	}
+
+	err := sink.store.Walk(func(result *pm.JobResult, ttl int) {
+		if sink.ch.Flagged(result.ID) {
+			return
+		}
+
+		sink.ch.Flag(result.ID, ttl, "")
+		sink.ch.UnFlag(result.ID, ttl)
+		if err := sink.ch.Respond(result, ttl); err != nil {
+			log.Errorf("failed to restore result of job %s: %s", result.ID, err)
+		}
+	})
+
+	if err != nil {
+		log.Errorf("failed to restore job results: %s", err)
+	}
 }
 
 //Start sink
 func (sink *Sink) Start() {
-	go sink.process()
+	sink.restore()
+
+	log.Infof("Starting %d sink worker(s) on queues %v", sink.workers, sink.queues)
+	for i := 0; i < sink.workers; i++ {
+		go sink.process()
+	}
+
+	go sink.monitor()
 }
 
 //GetResult gets a result of a job if it exists
@@ -166,5 +383,12 @@ func (sink *Sink) GetResult(job string, timeout int) (*pm.JobResult, error) {
 		return sink.ch.GetResponse(job, timeout)
 	}
 
+	if sink.store != nil {
+		//redis may have been restarted and lost the result
+		if result, err := sink.store.Get(job); err == nil {
+			return result, nil
+		}
+	}
+
 	return nil, fmt.Errorf("unknown job id '%s' (may be it has expired)", job)
 }
@@ -0,0 +1,203 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/pm"
+	"github.com/zero-os/0-core/base/settings"
+)
+
+const testSinkCommand = "test.sink"
+
+var testSinkRuns = struct {
+	ids []string
+	m   sync.Mutex
+}{}
+
+//testSinkRecorder records the order the sink starts the test commands
+type testSinkRecorder struct{}
+
+func (r testSinkRecorder) Pre(cmd *pm.Command) {
+	if cmd.Command != testSinkCommand {
+		return
+	}
+
+	testSinkRuns.m.Lock()
+	defer testSinkRuns.m.Unlock()
+	testSinkRuns.ids = append(testSinkRuns.ids, cmd.ID)
+}
+
+type testDepthRecorder struct {
+	depths map[string]float64
+}
+
+func (r *testDepthRecorder) Stats(op string, key string, value float64, id string, tags ...pm.Tag) {
+	if key == QueueDepthKey {
+		r.depths[id] = value
+	}
+}
+
+func init() {
+	pm.MaxJobs = 100
+	pm.New()
+	pm.Start()
+	pm.AddHandle(testSinkRecorder{})
+	pm.RegisterBuiltIn(testSinkCommand, func(cmd *pm.Command) (interface{}, error) {
+		return "done", nil
+	})
+}
+
+func testCommand(t *testing.T, id string) []byte {
+	data, err := json.Marshal(&pm.Command{ID: id, Command: testSinkCommand, Arguments: pm.MustArguments(nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+//waitResult waits for a worker to pick up the command, then for its result
+func waitResult(sink *Sink, id string) (*pm.JobResult, error) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !sink.ch.Flagged(id) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return sink.GetResult(id, 5)
+}
+
+func TestSinkQueuesPriority(t *testing.T) {
+	c := SinkConfig{
+		Queues: []settings.SinkQueue{
+			{Name: "core:bulk", Priority: 0},
+			{Name: "core:urgent", Priority: 10},
+			{Name: "core:default", Priority: 5},
+			{Name: "core:urgent", Priority: 1},
+			{Name: "", Priority: 20},
+		},
+	}
+
+	assert.Equal(t, []string{"core:urgent", "core:default", "core:bulk"}, c.queues())
+
+	c = SinkConfig{}
+	assert.Equal(t, []string{SinkQueueUrgent, SinkQueue, SinkQueueBulk}, c.queues())
+}
+
+func TestSinkWorkers(t *testing.T) {
+	c := SinkConfig{}
+	assert.Equal(t, DefaultSinkWorkers, c.workers())
+	c.Workers = 100
+	assert.Equal(t, MaxSinkWorkers, c.workers())
+}
+
+func TestSinkConsume(t *testing.T) {
+	fake := newFakeRedis()
+	defer fake.use()()
+
+	sink, err := NewSink(SinkConfig{})
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	testSinkRuns.m.Lock()
+	testSinkRuns.ids = nil
+	testSinkRuns.m.Unlock()
+
+	//commands are queued before the worker starts, the urgent ones must be served first
+	fake.push(SinkQueueBulk, testCommand(t, "consume-bulk"))
+	fake.push(SinkQueue, testCommand(t, "consume-default"))
+	fake.push(SinkQueueUrgent, testCommand(t, "consume-urgent-1"), testCommand(t, "consume-urgent-2"))
+
+	sink.Start()
+
+	for _, id := range []string{"consume-bulk", "consume-default", "consume-urgent-1", "consume-urgent-2"} {
+		result, err := waitResult(sink, id)
+		if assert.NoError(t, err, id) {
+			assert.Equal(t, pm.StateSuccess, result.State, id)
+		}
+	}
+
+	testSinkRuns.m.Lock()
+	var order []string
+	for _, id := range testSinkRuns.ids {
+		if strings.HasPrefix(id, "consume-") {
+			order = append(order, id)
+		}
+	}
+	testSinkRuns.m.Unlock()
+
+	assert.Equal(t, []string{"consume-urgent-1", "consume-urgent-2", "consume-default", "consume-bulk"}, order)
+}
+
+func TestSinkWorkersPool(t *testing.T) {
+	fake := newFakeRedis()
+	defer fake.use()()
+
+	sink, err := NewSink(SinkConfig{Workers: MaxSinkWorkers})
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	sink.Start()
+
+	//all the workers block on the empty queues with their own connections
+	deadline := time.Now().Add(2 * time.Second)
+	for sink.ch.workers.ActiveCount() < MaxSinkWorkers && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Equal(t, MaxSinkWorkers, sink.ch.workers.ActiveCount())
+	assert.Equal(t, 0, sink.pool.ActiveCount())
+
+	//the main pool is still available
+	done := make(chan error, PoolSize)
+	for i := 0; i < PoolSize; i++ {
+		go func(i int) {
+			done <- sink.Set(fmt.Sprintf("key-%d", i), []byte("value"))
+		}(i)
+	}
+
+	for i := 0; i < PoolSize; i++ {
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(2 * time.Second):
+			t.Fatal("sink pool is starved by the workers")
+		}
+	}
+
+	//commands pushed while all workers wait are consumed
+	fake.push(SinkQueueBulk, testCommand(t, "pool-1"))
+	result, err := waitResult(sink, "pool-1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, pm.StateSuccess, result.State)
+	}
+}
+
+func TestSinkQueueDepth(t *testing.T) {
+	fake := newFakeRedis()
+	defer fake.use()()
+
+	sink, err := NewSink(SinkConfig{
+		Queues: []settings.SinkQueue{{Name: "core:a", Priority: 1}, {Name: "core:b"}},
+	})
+
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	recorder := &testDepthRecorder{depths: make(map[string]float64)}
+	pm.AddHandle(recorder)
+
+	fake.push("core:a", []byte("1"), []byte("2"))
+	fake.push("core:b", []byte("3"))
+
+	sink.depth()
+	assert.Equal(t, map[string]float64{"core:a": 2, "core:b": 1}, recorder.depths)
+}
@@ -0,0 +1,135 @@
+package transport
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/zero-os/0-core/base/pm"
+)
+
+const (
+	ResultStoreCleanupInterval = 5 * time.Minute
+)
+
+/*
+resultStore keeps a copy of the job results on disk, so results can still be retrieved
+after a redis restart. Results are deleted from the store once their ttl expires.
+*/
+type resultStore struct {
+	root string
+}
+
+type storedResult struct {
+	Expires int64         `json:"expires"`
+	Result  *pm.JobResult `json:"result"`
+}
+
+func newResultStore(root string) (*resultStore, error) {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, err
+	}
+
+	store := &resultStore{root: root}
+	go store.cleanup()
+
+	return store, nil
+}
+
+//file gets the file name of the job result, job IDs are user defined, so we never
+//use them directly as file names
+func (s *resultStore) file(id string) string {
+	return path.Join(s.root, fmt.Sprintf("%x", md5.Sum([]byte(id))))
+}
+
+//Set stores the job result for ttl seconds
+func (s *resultStore) Set(result *pm.JobResult, ttl int) error {
+	data, err := json.Marshal(storedResult{
+		Expires: time.Now().Unix() + int64(ttl),
+		Result:  result,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	//write to a temp file then rename, so a crash never leaves a partial result behind
+	name := s.file(result.ID)
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, name)
+}
+
+func (s *resultStore) load(name string) (*storedResult, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored storedResult
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+
+	if stored.Result == nil || stored.Expires < time.Now().Unix() {
+		os.Remove(name)
+		return nil, os.ErrNotExist
+	}
+
+	return &stored, nil
+}
+
+//Get gets the stored job result, returns os.ErrNotExist if the job is unknown or
+//its result has expired
+func (s *resultStore) Get(id string) (*pm.JobResult, error) {
+	stored, err := s.load(s.file(id))
+	if err != nil {
+		return nil, err
+	}
+
+	return stored.Result, nil
+}
+
+//Walk calls fn with each of the stored results, and the ttl left for each of them
+func (s *resultStore) Walk(fn func(result *pm.JobResult, ttl int)) error {
+	items, err := ioutil.ReadDir(s.root)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, item := range items {
+		if item.IsDir() || path.Ext(item.Name()) == ".tmp" {
+			continue
+		}
+
+		stored, err := s.load(path.Join(s.root, item.Name()))
+		if err != nil {
+			continue
+		}
+
+		if ttl := int(stored.Expires - now); ttl > 0 {
+			fn(stored.Result, ttl)
+		}
+	}
+
+	return nil
+}
+
+func (s *resultStore) cleanup() {
+	for {
+		//loading the results drops the expired ones
+		if err := s.Walk(func(*pm.JobResult, int) {}); err != nil {
+			log.Errorf("failed to clean up result store: %s", err)
+		}
+
+		<-time.After(ResultStoreCleanupInterval)
+	}
+}
@@ -0,0 +1,47 @@
+package transport
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/pm"
+)
+
+func TestResultStore(t *testing.T) {
+	root, err := ioutil.TempDir("", "results")
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+	defer os.RemoveAll(root)
+
+	store := &resultStore{root: root}
+
+	if !assert.NoError(t, store.Set(&pm.JobResult{ID: "../job-1", State: pm.StateSuccess}, 60)) {
+		t.Fatal()
+	}
+
+	result, err := store.Get("../job-1")
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	assert.Equal(t, pm.StateSuccess, result.State)
+
+	//expired result
+	if !assert.NoError(t, store.Set(&pm.JobResult{ID: "job-2"}, -1)) {
+		t.Fatal()
+	}
+
+	_, err = store.Get("job-2")
+	assert.True(t, os.IsNotExist(err))
+
+	var ids []string
+	store.Walk(func(result *pm.JobResult, ttl int) {
+		ids = append(ids, result.ID)
+		assert.True(t, ttl > 0 && ttl <= 60)
+	})
+
+	assert.Equal(t, []string{"../job-1"}, ids)
+}
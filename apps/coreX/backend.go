@@ -19,6 +19,7 @@ const (
 type Message struct {
 	Type    MessageType `json:"type"`
 	Command string      `json:"command"`
+	Tags    pm.Tags     `json:"tags,omitempty"`
 	Payload interface{} `json:"payload"`
 }
 type Dispatcher struct {
@@ -46,7 +47,7 @@ func (d *Dispatcher) Message(cmd *pm.Command, msg *stream.Message) {
 	d.m.Lock()
 	defer d.m.Unlock()
 
-	d.enc.Encode(Message{Type: LogMessage, Command: cmd.ID, Payload: msg})
+	d.enc.Encode(Message{Type: LogMessage, Command: cmd.ID, Tags: cmd.Tags, Payload: msg})
 }
 
 func (d *Dispatcher) Stats(operation string, key string, value float64, id string, tags ...pm.Tag) {
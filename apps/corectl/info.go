@@ -50,3 +50,7 @@ func info_nic(t Transport, c *cli.Context) {
 func info_os(t Transport, c *cli.Context) {
 	info(t, "info.os")
 }
+
+func info_sensors(t Transport, c *cli.Context) {
+	info(t, "info.sensors")
+}
@@ -136,6 +136,11 @@ func main() {
 					Usage:  "display OS info",
 					Action: WithTransport(info_os),
 				},
+				{
+					Name:   "sensors",
+					Usage:  "display hardware sensors",
+					Action: WithTransport(info_sensors),
+				},
 			},
 		},
 		{
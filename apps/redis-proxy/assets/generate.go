@@ -1,3 +0,0 @@
-package assets
-
-//go:generate go-bindata -pkg assets text
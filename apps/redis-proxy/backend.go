@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+//backend is the connection of a single client to the proxied redis, a client always uses
+//the same connection so stateful commands (MULTI, SUBSCRIBE, WATCH, SELECT) work.
+type backend struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	budget *budget
+	o      sync.Once
+}
+
+//dial opens a redis connection, if there is a free slot in the connections budget
+func dial(socket string, b *budget) (*backend, error) {
+	if !b.acquire() {
+		return nil, fmt.Errorf("no redis connection available, retry later")
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		b.release()
+		return nil, err
+	}
+
+	return &backend{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		budget: b,
+	}, nil
+}
+
+//send writes a command to redis
+func (b *backend) send(args [][]byte) error {
+	_, err := b.conn.Write(encode(args))
+	return err
+}
+
+//receive reads a single reply from redis, as is
+func (b *backend) receive() ([]byte, error) {
+	return readReply(b.reader, nil)
+}
+
+//Close the connection and free its slot of the budget
+func (b *backend) Close() error {
+	b.o.Do(b.budget.release)
+	return b.conn.Close()
+}
+
+//encode a command as a RESP array of bulk strings
+func encode(args [][]byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&buf, "$%d\r\n", len(arg))
+		buf.Write(arg)
+		buf.WriteString("\r\n")
+	}
+
+	return buf.Bytes()
+}
+
+//respError encodes an error reply
+func respError(msg string) []byte {
+	return []byte("-" + msg + "\r\n")
+}
+
+//respOK is the OK status reply
+var respOK = []byte("+OK\r\n")
+
+//readReply reads a complete RESP2 reply, including all the nested elements of arrays, and
+//appends the raw frame to buf. RESP3 is never negotiated, the proxy rejects HELLO 3.
+func readReply(r *bufio.Reader, buf []byte) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return buf, err
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return buf, fmt.Errorf("invalid reply line: %q", line)
+	}
+
+	buf = append(buf, line...)
+
+	switch line[0] {
+	case '+', '-', ':':
+		return buf, nil
+	case '$':
+		size, err := strconv.Atoi(string(line[1 : len(line)-2]))
+		if err != nil {
+			return buf, fmt.Errorf("invalid bulk length: %q", line)
+		}
+
+		if size < 0 {
+			return buf, nil
+		}
+
+		bulk := make([]byte, size+2)
+		if _, err := io.ReadFull(r, bulk); err != nil {
+			return buf, err
+		}
+
+		return append(buf, bulk...), nil
+	case '*':
+		count, err := strconv.Atoi(string(line[1 : len(line)-2]))
+		if err != nil {
+			return buf, fmt.Errorf("invalid array length: %q", line)
+		}
+
+		for i := 0; i < count; i++ {
+			if buf, err = readReply(r, buf); err != nil {
+				return buf, err
+			}
+		}
+
+		return buf, nil
+	default:
+		return buf, fmt.Errorf("invalid reply type: %q", line[0])
+	}
+}
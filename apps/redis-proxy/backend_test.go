@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadReply(t *testing.T) {
+	replies := []string{
+		"+OK\r\n",
+		"-ERR unknown command\r\n",
+		":42\r\n",
+		"$-1\r\n",
+		"$5\r\nhello\r\n",
+		"*-1\r\n",
+		"*0\r\n",
+		//nested reply, like the one of EXEC or XRANGE
+		"*2\r\n*2\r\n:1\r\n$1\r\na\r\n*1\r\n$-1\r\n",
+	}
+
+	var stream bytes.Buffer
+	for _, reply := range replies {
+		stream.WriteString(reply)
+	}
+
+	reader := bufio.NewReader(&stream)
+	for _, expected := range replies {
+		reply, err := readReply(reader, nil)
+		if !assert.NoError(t, err) {
+			t.Fatal()
+		}
+
+		assert.Equal(t, expected, string(reply))
+	}
+}
+
+func TestEncode(t *testing.T) {
+	assert.Equal(t, "*2\r\n$4\r\nLLEN\r\n$12\r\ncore:default\r\n", string(encode([][]byte{[]byte("LLEN"), []byte("core:default")})))
+}
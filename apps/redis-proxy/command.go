@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/zero-os/0-core/base/auth"
+)
+
+const (
+	//CommandQueuePrefix all core0 command queues starts with this prefix, core0 refuses to
+	//start with a sink queue that doesn't
+	CommandQueuePrefix = "core:"
+	//IdentityAnonymous is the identity of clients when no authentication is required
+	IdentityAnonymous = "anonymous"
+
+	cmdContainerDispatch = "corex.dispatch"
+)
+
+func isCommandQueue(key string) bool {
+	return strings.HasPrefix(key, CommandQueuePrefix)
+}
+
+//session of a connected client
+type session struct {
+	identity *auth.Identity
+	//commands patterns the client is allowed to run, nil means all commands are allowed
+	commands []string
+}
+
+//restricted is true if the client is not allowed to run all commands
+func (s *session) restricted() bool {
+	return s.commands != nil && !auth.Allowed(s.commands, "*")
+}
+
+//authorize checks if the command, and for container dispatches the command that will run
+//inside the container, is allowed for this session
+func (s *session) authorize(command map[string]*json.RawMessage) error {
+	var name string
+	if raw, ok := command["command"]; ok && raw != nil {
+		if err := json.Unmarshal(*raw, &name); err != nil {
+			return fmt.Errorf("invalid command name")
+		}
+	}
+
+	if s.commands != nil && !auth.Allowed(s.commands, name) {
+		return fmt.Errorf("permission denied: command '%s' is not allowed", name)
+	}
+
+	if name != cmdContainerDispatch {
+		return nil
+	}
+
+	var args struct {
+		Command map[string]*json.RawMessage `json:"command"`
+	}
+
+	if raw, ok := command["arguments"]; ok && raw != nil {
+		if err := json.Unmarshal(*raw, &args); err != nil {
+			return fmt.Errorf("invalid %s arguments", cmdContainerDispatch)
+		}
+	}
+
+	return s.authorize(args.Command)
+}
+
+//prepare authorizes a command payload pushed to a command queue and sets the identity of
+//the caller on it, overriding any identity the client could have set. Payloads that are not
+//commands are returned as is, unless the session is restricted.
+func (s *session) prepare(payload []byte) ([]byte, error) {
+	var command map[string]*json.RawMessage
+	if err := json.Unmarshal(payload, &command); err != nil {
+		if s.restricted() {
+			return nil, fmt.Errorf("permission denied: invalid command payload")
+		}
+		return payload, nil
+	}
+
+	if err := s.authorize(command); err != nil {
+		return nil, err
+	}
+
+	value, _ := json.Marshal(s.identity.Name)
+	raw := json.RawMessage(value)
+	command["identity"] = &raw
+
+	stamped, err := json.Marshal(command)
+	if err != nil {
+		return payload, nil
+	}
+
+	return stamped, nil
+}
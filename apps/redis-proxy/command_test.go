@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/auth"
+)
+
+func TestSessionPrepare(t *testing.T) {
+	policy := &auth.Policy{
+		Role: map[string]auth.Role{
+			"monitoring": {
+				Organizations: []string{"org.monitoring"},
+				Commands:      []string{"info.*", "aggregator.query", "corex.dispatch"},
+			},
+		},
+	}
+
+	identity := &auth.Identity{Name: "monitor", Scopes: []string{auth.MemberOf("org.monitoring")}}
+	s := &session{identity: identity, commands: policy.Commands(identity)}
+
+	assert.True(t, s.restricted())
+
+	payload, err := s.prepare([]byte(`{"id": "1", "command": "info.cpu", "identity": "root"}`))
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	var command map[string]interface{}
+	if !assert.NoError(t, json.Unmarshal(payload, &command)) {
+		t.Fatal()
+	}
+	assert.Equal(t, "monitor", command["identity"])
+
+	_, err = s.prepare([]byte(`{"id": "2", "command": "core.reboot"}`))
+	assert.EqualError(t, err, "permission denied: command 'core.reboot' is not allowed")
+
+	_, err = s.prepare([]byte(`{"id": "3", "command": "corex.dispatch", "arguments": {"container": 1, "command": {"command": "info.mem"}}}`))
+	assert.NoError(t, err)
+
+	_, err = s.prepare([]byte(`{"id": "4", "command": "corex.dispatch", "arguments": {"container": 1, "command": {"command": "core.system"}}}`))
+	assert.EqualError(t, err, "permission denied: command 'core.system' is not allowed")
+
+	_, err = s.prepare([]byte(`not a command`))
+	assert.Error(t, err)
+}
+
+func TestSessionUnrestricted(t *testing.T) {
+	s := &session{identity: &auth.Identity{Name: IdentityAnonymous}}
+
+	assert.False(t, s.restricted())
+
+	_, err := s.prepare([]byte(`{"id": "1", "command": "core.reboot"}`))
+	assert.NoError(t, err)
+
+	payload, err := s.prepare([]byte(`not a command`))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(`not a command`), payload)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	//AuthBackoffBase is the wait after the first failed AUTH, doubled on each following failure
+	AuthBackoffBase = time.Second
+	//AuthBackoffMax is the maximum wait between AUTH attempts
+	AuthBackoffMax = time.Minute
+
+	//limitsCleanupInterval how often the state of idle clients is dropped
+	limitsCleanupInterval = 5 * time.Minute
+)
+
+//host of a client address, so all the connections of a client share the same limits
+func host(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+
+	return addr
+}
+
+//budget caps the number of redis connections opened by the proxy, so the connections
+//reserved for core0 are always available
+type budget struct {
+	max  int32
+	used int32
+}
+
+func newBudget(max int) *budget {
+	return &budget{max: int32(max)}
+}
+
+//acquire takes a connection slot, it returns false if the budget is exhausted
+func (b *budget) acquire() bool {
+	if atomic.AddInt32(&b.used, 1) > b.max {
+		atomic.AddInt32(&b.used, -1)
+		return false
+	}
+
+	return true
+}
+
+func (b *budget) release() {
+	atomic.AddInt32(&b.used, -1)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+//limiter is a token bucket rate limiter per key, each key can run rate commands per
+//second with bursts up to burst commands.
+type limiter struct {
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+	m       sync.Mutex
+}
+
+func newLimiter(rate, burst int) *limiter {
+	if burst < rate {
+		burst = rate
+	}
+
+	return &limiter{
+		rate:    float64(rate),
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+//allow takes a token from the key bucket, it returns false if the bucket is empty
+func (l *limiter) allow(key string) bool {
+	if l.rate <= 0 {
+		return true
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+//cleanup drops the buckets that are full again
+func (l *limiter) cleanup() {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	now := time.Now()
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+type failures struct {
+	count int
+	until time.Time
+}
+
+//backoff tracks failed AUTH attempts per client, each failure doubles the time the client
+//has to wait before it can try again.
+type backoff struct {
+	clients map[string]*failures
+	m       sync.Mutex
+}
+
+func newBackoff() *backoff {
+	return &backoff{
+		clients: make(map[string]*failures),
+	}
+}
+
+//wait gets how long the client has to wait before it can try to authenticate
+func (b *backoff) wait(key string) time.Duration {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	f, ok := b.clients[key]
+	if !ok {
+		return 0
+	}
+
+	if wait := f.until.Sub(time.Now()); wait > 0 {
+		return wait
+	}
+
+	return 0
+}
+
+func (b *backoff) failed(key string) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	f, ok := b.clients[key]
+	if !ok {
+		f = &failures{}
+		b.clients[key] = f
+	}
+
+	wait := AuthBackoffBase << uint(f.count)
+	if wait > AuthBackoffMax || wait <= 0 {
+		wait = AuthBackoffMax
+	}
+
+	f.count++
+	f.until = time.Now().Add(wait)
+}
+
+func (b *backoff) succeeded(key string) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	delete(b.clients, key)
+}
+
+//cleanup forgets the failures of clients that didn't try again for a while
+func (b *backoff) cleanup() {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	now := time.Now()
+	for key, f := range b.clients {
+		if now.Sub(f.until) > AuthBackoffMax {
+			delete(b.clients, key)
+		}
+	}
+}
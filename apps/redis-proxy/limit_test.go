@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(1, 3)
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, l.allow("a"))
+	}
+
+	assert.False(t, l.allow("a"))
+	//keys have their own buckets
+	assert.True(t, l.allow("b"))
+
+	unlimited := newLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		assert.True(t, unlimited.allow("a"))
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff()
+
+	assert.Equal(t, time.Duration(0), b.wait("a"))
+
+	b.failed("a")
+	wait := b.wait("a")
+	assert.True(t, wait > 0 && wait <= AuthBackoffBase)
+
+	b.failed("a")
+	assert.True(t, b.wait("a") > AuthBackoffBase)
+
+	b.succeeded("a")
+	assert.Equal(t, time.Duration(0), b.wait("a"))
+}
+
+func TestBudget(t *testing.T) {
+	b := newBudget(2)
+
+	assert.True(t, b.acquire())
+	assert.True(t, b.acquire())
+	assert.False(t, b.acquire())
+
+	b.release()
+	assert.True(t, b.acquire())
+}
@@ -35,6 +35,71 @@ func main() {
 			Value: "/var/run/redis.sock",
 			Usage: "redis unix socket to proxy",
 		},
+		cli.StringFlag{
+			Name:  "tokens",
+			Value: "",
+			Usage: "file of static bearer tokens accepted by AUTH, one '<token> <name> [scope...]' per line",
+		},
+		cli.StringFlag{
+			Name:  "jwt-key",
+			Value: "",
+			Usage: "public key file (PEM) of a custom JWT issuer, JWTs signed with this key are accepted by AUTH",
+		},
+		cli.StringFlag{
+			Name:  "jwt-claim",
+			Value: "scope",
+			Usage: "claim that holds the caller scopes in the custom issuer JWTs",
+		},
+		cli.StringFlag{
+			Name:  "cert",
+			Value: "",
+			Usage: "server certificate file (PEM), if not provided, it will be parsed from kernel cmdline (proxy-cert) or the tls config file, otherwise a self signed certificate is used",
+		},
+		cli.StringFlag{
+			Name:  "key",
+			Value: "",
+			Usage: "server key file (PEM), if not provided, it will be parsed from kernel cmdline (proxy-key) or the tls config file",
+		},
+		cli.StringFlag{
+			Name:  "tls-config",
+			Value: "",
+			Usage: "toml file with the 'cert', 'key' and 'client_ca' paths, used for the ones that are not set by flags",
+		},
+		cli.StringFlag{
+			Name:  "client-ca",
+			Value: "",
+			Usage: "CA certificates file (PEM), clients that present a certificate signed by it are authenticated, if not provided, it will be parsed from kernel cmdline (proxy-client-ca) or the tls config file",
+		},
+		cli.StringFlag{
+			Name:  "policy, p",
+			Value: "",
+			Usage: "policy file that maps jwt scopes to allowed commands, if it doesn't exist, members of the organization are allowed all commands",
+		},
+		cli.IntFlag{
+			Name:  "max-connections",
+			Value: DefaultMaxConnections,
+			Usage: "maximum number of connected clients",
+		},
+		cli.IntFlag{
+			Name:  "redis-max-clients",
+			Value: DefaultRedisMaxClients,
+			Usage: "maximum number of clients of the proxied redis (its maxclients setting)",
+		},
+		cli.IntFlag{
+			Name:  "reserved",
+			Value: DefaultReservedConnections,
+			Usage: "number of redis connections the proxy never uses, they are reserved for core0",
+		},
+		cli.IntFlag{
+			Name:  "rate",
+			Value: DefaultRate,
+			Usage: "commands per second allowed per client address and per identity (0 for unlimited)",
+		},
+		cli.IntFlag{
+			Name:  "burst",
+			Value: 2 * DefaultRate,
+			Usage: "maximum number of commands a client can send at once",
+		},
 		cli.BoolFlag{
 			Name:  "debug",
 			Usage: "enable debug logging",
@@ -59,10 +124,67 @@ func main() {
 			}
 		}
 
-		return Proxy(ctx.String("listen"), ctx.String("redis"), organization)
+		config := Config{
+			Listen:       ctx.String("listen"),
+			Redis:        ctx.String("redis"),
+			Organization: organization,
+			Tokens:       ctx.String("tokens"),
+			JWTKey:       ctx.String("jwt-key"),
+			JWTClaim:     ctx.String("jwt-claim"),
+			Cert:         ctx.String("cert"),
+			Key:          ctx.String("key"),
+			ClientCA:     ctx.String("client-ca"),
+			Policy:       ctx.String("policy"),
+
+			MaxConnections:  ctx.Int("max-connections"),
+			RedisMaxClients: ctx.Int("redis-max-clients"),
+			Reserved:        ctx.Int("reserved"),
+			Rate:            ctx.Int("rate"),
+			Burst:           ctx.Int("burst"),
+		}
+
+		if err := certificates(&config, ctx.String("tls-config")); err != nil {
+			return err
+		}
+
+		return Proxy(config)
 	}
 
 	if err := app.Run(os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
+
+//certificates fills the certificate paths that are not set by flags, from the tls config
+//file first, then from the kernel cmdline
+func certificates(config *Config, file string) error {
+	var fromFile Config
+	if file != "" {
+		if err := utils.LoadTomlFile(file, &fromFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	options := utils.GetKernelOptions()
+	for _, path := range []struct {
+		value  *string
+		file   string
+		kernel string
+	}{
+		{&config.Cert, fromFile.Cert, "proxy-cert"},
+		{&config.Key, fromFile.Key, "proxy-key"},
+		{&config.ClientCA, fromFile.ClientCA, "proxy-client-ca"},
+	} {
+		if *path.value != "" {
+			continue
+		}
+
+		if path.file != "" {
+			*path.value = path.file
+		} else if values, ok := options.Get(path.kernel); ok {
+			*path.value = values[len(values)-1]
+		}
+	}
+
+	return nil
+}
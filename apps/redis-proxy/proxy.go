@@ -1,65 +1,185 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/garyburd/redigo/redis"
 	"github.com/tidwall/redcon"
-	"github.com/zero-os/0-core/apps/redis-proxy/assets"
+	"github.com/zero-os/0-core/base/auth"
 )
 
-func newPool(socket string) *redis.Pool {
-	return &redis.Pool{
-		Dial: func() (redis.Conn, error) {
-			return redis.Dial("unix", socket)
-		},
-		TestOnBorrow: func(c redis.Conn, t time.Time) error {
-			_, err := c.Do("PING")
-			return err
-		},
-		MaxActive: 20,
-		Wait:      true,
-	}
-}
+const (
+	//SelfSignedCert and SelfSignedKey are where the self signed certificate is kept, so it
+	//survives restarts
+	SelfSignedCert = "/var/cache/redis-proxy/proxy.crt"
+	SelfSignedKey  = "/var/cache/redis-proxy/proxy.key"
+
+	//DefaultMaxConnections is the default maximum number of connected clients
+	DefaultMaxConnections = 256
+	//DefaultRedisMaxClients is the maximum number of clients the proxied redis accepts, as set
+	//by its startup config
+	DefaultRedisMaxClients = 1024
+	//DefaultReservedConnections is the default number of redis connections the proxy never
+	//uses, they are left for core0 (sink, workers, result waits, loggers and stats)
+	DefaultReservedConnections = 128
+	//DefaultRate is the default number of commands per second allowed per client address
+	//and per identity
+	DefaultRate = 100
+)
 
 type redisProxy struct {
-	pool       *redis.Pool
-	authMethod func(string) bool
+	socket     string
+	authMethod auth.Method
+	policy     *auth.Policy
 	doAuth     bool
+	certAuth   bool
+
+	maxConnections int32
+	connections    int32
+	backends       *budget
+	addrLimit      *limiter
+	identityLimit  *limiter
+	backoff        *backoff
 }
 
-//liste to core0 port and
-func Proxy(listen, redis, organization string) error {
-	authMethod := func(_ string) bool {
-		return true
-	}
+//Config of the proxy authentication backends, all configured backends are tried in order
+type Config struct {
+	Listen string
+	Redis  string
+	//Organization IYO organization, JWTs issued by itsyou.online for the organization are accepted
+	Organization string
+	//Tokens file of static bearer tokens
+	Tokens string
+	//JWTKey file of the public key of a custom JWT issuer
+	JWTKey string
+	//JWTClaim holds the caller scopes in custom issuer JWTs
+	JWTClaim string
+	//Cert and Key files of the server certificate, if not set a self signed certificate is used
+	Cert string `toml:"cert"`
+	Key  string `toml:"key"`
+	//ClientCA file of the CA certificates, client certificates signed by it are accepted
+	ClientCA string `toml:"client_ca"`
+	//Policy file that maps scopes to allowed commands
+	Policy string
 
-	doAuth := false
+	//MaxConnections maximum number of connected clients
+	MaxConnections int
+	//RedisMaxClients the maximum number of clients of the proxied redis, and Reserved the
+	//number of them the proxy leaves for core0, the proxy opens at most
+	//RedisMaxClients-Reserved redis connections
+	RedisMaxClients int
+	Reserved        int
+	//Rate commands per second allowed per client address and per identity, and Burst the
+	//maximum number of commands that can be sent at once
+	Rate  int
+	Burst int
+}
 
-	if organization != "" {
-		var err error
-		authMethod, err = AuthMethod(organization, string(assets.MustAsset("text/itsyouonline.pub")))
-		if err != nil {
-			return err
+//auth gets the authentication options of the proxy
+func (c *Config) auth() auth.Options {
+	return auth.Options{
+		Organization: c.Organization,
+		Tokens:       c.Tokens,
+		JWTKey:       c.JWTKey,
+		JWTClaim:     c.JWTClaim,
+		ClientCA:     c.ClientCA,
+		Policy:       c.Policy,
+	}
+}
+
+//tlsConfig loads the configured certificates, a self signed certificate is used if none
+//is configured. The certificates are reloaded on SIGHUP or when the files change.
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	if c.Cert == "" && c.Key == "" {
+		c.Cert, c.Key = SelfSignedCert, SelfSignedKey
+		if err := auth.SelfSigned(c.Cert, c.Key); err != nil {
+			return nil, err
 		}
-		doAuth = true
+	} else if c.Cert == "" || c.Key == "" {
+		return nil, fmt.Errorf("both certificate and key must be set")
+	}
+
+	certs, err := auth.NewCertificates(c.Cert, c.Key, c.ClientCA)
+	if err != nil {
+		return nil, err
+	}
+
+	certs.Watch()
+	return certs.Config(), nil
+}
+
+//backends gets the budget of redis connections of the proxy
+func (c *Config) backends() (*budget, error) {
+	max, reserved := c.RedisMaxClients, c.Reserved
+	if max <= 0 {
+		max = DefaultRedisMaxClients
 	}
 
+	if reserved <= 0 {
+		reserved = DefaultReservedConnections
+	}
+
+	if reserved >= max {
+		return nil, fmt.Errorf("reserved connections (%d) must be less than redis max clients (%d)", reserved, max)
+	}
+
+	return newBudget(max - reserved), nil
+}
+
+//liste to core0 port and
+func Proxy(c Config) error {
 	p := redisProxy{
-		pool:       newPool(redis),
-		authMethod: authMethod,
-		doAuth:     doAuth,
+		socket: c.Redis,
+		authMethod: func(_ string) (*auth.Identity, bool) {
+			return &auth.Identity{Name: IdentityAnonymous}, true
+		},
+		maxConnections: int32(c.MaxConnections),
+		addrLimit:      newLimiter(c.Rate, c.Burst),
+		identityLimit:  newLimiter(c.Rate, c.Burst),
+		backoff:        newBackoff(),
+	}
+
+	if p.maxConnections <= 0 {
+		p.maxConnections = DefaultMaxConnections
+	}
+
+	backends, err := c.backends()
+	if err != nil {
+		return err
 	}
+	p.backends = backends
 
-	tlsConfig, err := generateCRT()
+	go p.cleanup()
+
+	options := c.auth()
+	methods, err := options.Methods()
 	if err != nil {
 		return err
 	}
 
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	p.certAuth = c.ClientCA != ""
+
+	if len(methods) != 0 || p.certAuth {
+		p.authMethod = auth.Chain(methods...)
+		p.doAuth = true
+		if p.policy, err = options.LoadPolicy(); err != nil {
+			return err
+		}
+	} else if c.Policy != "" {
+		log.Warningf("no authentication is configured, policy '%s' is ignored", c.Policy)
+	}
+
 	return redcon.ListenAndServeTLS(
-		listen,
+		c.Listen,
 		p.handler,
 		p.accept,
 		p.closed,
@@ -67,99 +187,450 @@ func Proxy(listen, redis, organization string) error {
 	)
 }
 
-func (r *redisProxy) auth(conn redcon.Conn, cmd redcon.Command) {
+//client state of a connection
+type client struct {
+	//addr is the client host, limits are applied per host
+	addr string
+	//session is nil until the client is authenticated
+	session *session
+	backend *backend
+	//relay is set once the client subscribed to a channel, from then on replies are
+	//relayed to the client as they come
+	relay bool
+}
+
+func (r *redisProxy) auth(c *client, cmd redcon.Command) []byte {
 	if len(cmd.Args) != 2 {
-		conn.WriteError("invalid number of arguments")
-		return
+		return respError("invalid number of arguments")
+	}
+
+	if wait := r.backoff.wait(c.addr); wait > 0 {
+		return respError(fmt.Sprintf("too many failed attempts, retry in %s", wait.Round(time.Second)))
 	}
 
 	password := string(cmd.Args[1])
 
-	if r.authMethod(password) {
-		conn.SetContext(true)
-		conn.WriteString("OK")
-	} else {
-		conn.WriteError("invalid jwt")
+	identity, ok := r.authMethod(password)
+	if !ok {
+		log.Warningf("failed authentication from %s", c.addr)
+		r.backoff.failed(c.addr)
+		return respError("invalid credentials")
 	}
-}
 
-func (r *redisProxy) proxy(conn redcon.Conn, cmd redcon.Command) {
-	// is authorized ?
-	if ctx := conn.Context(); r.doAuth && ctx == nil {
-		//ctx was not set, hence he either didn't call auth or not authorized
-		conn.WriteError("permission denied, please call AUTH first with a valid JWT")
-		return
-	}
+	r.backoff.succeeded(c.addr)
 
-	//proxy to underlying redis
-	local := r.pool.Get()
-	defer local.Close()
+	if err := r.login(c, identity); err != nil {
+		return respError(err.Error())
+	}
 
-	args := make([]interface{}, 0, len(cmd.Args)-1)
+	return respOK
+}
 
-	for _, arg := range cmd.Args[1:] {
-		args = append(args, arg)
+//login starts the session of an authenticated client
+func (r *redisProxy) login(c *client, identity *auth.Identity) error {
+	s := &session{identity: identity}
+	if r.policy != nil {
+		s.commands = r.policy.Commands(identity)
+		if len(s.commands) == 0 {
+			return fmt.Errorf("permission denied, no role is granted to '%s'", identity.Name)
+		}
 	}
 
-	result, err := local.Do(string(cmd.Args[0]), args...)
+	c.session = s
+	return nil
+}
 
-	if err != nil {
-		conn.WriteError(err.Error())
+//certificate authenticates the client with its certificate, if it presented a valid one
+func (r *redisProxy) certificate(conn redcon.Conn, c *client) {
+	tlsConn, ok := conn.NetConn().(*tls.Conn)
+	if !ok {
 		return
-	} else if result == nil {
-		conn.WriteNull()
+	}
+
+	state := tlsConn.ConnectionState()
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
 		return
 	}
 
-	write := func(conn redcon.Conn, result interface{}) {
-		switch result := result.(type) {
-		case error:
-			conn.WriteError(result.Error())
-		case int64:
-			conn.WriteInt64(result)
-		case string:
-			conn.WriteString(result)
-		case []byte:
-			conn.WriteBulk(result)
-		default:
-			conn.WriteError(fmt.Sprintf("unhandled return type: %T(%v)", result, result))
+	identity := auth.CertificateIdentity(state.VerifiedChains[0][0])
+	if err := r.login(c, identity); err != nil {
+		log.Debugf("client certificate of %s: %s", conn.RemoteAddr(), err)
+	}
+}
+
+//queueArgument gets the index of the argument that is the key written to by list commands
+//that can move values into a command queue
+var queueArgument = map[string]int{
+	"linsert":    1,
+	"lset":       1,
+	"rpoplpush":  2,
+	"brpoplpush": 2,
+	"lmove":      2,
+	"blmove":     2,
+	"rename":     2,
+	"renamenx":   2,
+	"copy":       2,
+	"restore":    1,
+}
+
+//restrictedCommands are the data commands a restricted client can run. Server administration,
+//replication, persistence and scripting are reserved to the clients allowed to run all commands,
+//since they bypass the authorization of the pushed commands (or write files as root).
+var restrictedCommands = map[string]bool{
+	//connection
+	"ping": true, "echo": true, "hello": true, "client": true, "time": true,
+	//keys
+	"del": true, "unlink": true, "exists": true, "type": true, "keys": true, "scan": true,
+	"expire": true, "pexpire": true, "expireat": true, "pexpireat": true, "persist": true,
+	"ttl": true, "pttl": true, "rename": true, "renamenx": true, "copy": true, "sort": true,
+	"lkeyexists": true, "lttl": true,
+	//strings
+	"get": true, "set": true, "setnx": true, "setex": true, "psetex": true, "getset": true,
+	"getdel": true, "getex": true, "mget": true, "mset": true, "msetnx": true, "append": true,
+	"strlen": true, "incr": true, "incrby": true, "incrbyfloat": true, "decr": true, "decrby": true,
+	"getrange": true, "setrange": true, "getbit": true, "setbit": true, "bitcount": true, "bitpos": true,
+	//lists
+	"lpush": true, "rpush": true, "lpushx": true, "rpushx": true, "lpop": true, "rpop": true,
+	"blpop": true, "brpop": true, "rpoplpush": true, "brpoplpush": true, "lmove": true, "blmove": true,
+	"llen": true, "lindex": true, "lrange": true, "lrem": true, "lset": true, "ltrim": true,
+	"linsert": true, "lpos": true,
+	//hashes
+	"hget": true, "hset": true, "hsetnx": true, "hmset": true, "hmget": true, "hdel": true,
+	"hexists": true, "hgetall": true, "hkeys": true, "hvals": true, "hlen": true, "hincrby": true,
+	"hincrbyfloat": true, "hscan": true, "hstrlen": true,
+	//sets
+	"sadd": true, "srem": true, "smembers": true, "sismember": true, "scard": true, "spop": true,
+	"srandmember": true, "smove": true, "sinter": true, "sunion": true, "sdiff": true,
+	"sinterstore": true, "sunionstore": true, "sdiffstore": true, "sscan": true,
+	//sorted sets
+	"zadd": true, "zrem": true, "zcard": true, "zcount": true, "zscore": true, "zincrby": true,
+	"zrank": true, "zrevrank": true, "zrange": true, "zrevrange": true, "zrangebyscore": true,
+	"zrevrangebyscore": true, "zremrangebyrank": true, "zremrangebyscore": true, "zpopmin": true,
+	"zpopmax": true, "bzpopmin": true, "bzpopmax": true, "zscan": true,
+	//pub/sub
+	"subscribe": true, "psubscribe": true, "ssubscribe": true, "unsubscribe": true,
+	"punsubscribe": true, "sunsubscribe": true, "publish": true,
+	//transactions
+	"multi": true, "exec": true, "discard": true, "watch": true, "unwatch": true,
+}
+
+//restrictedClientCommands are the client subcommands a restricted client can run
+var restrictedClientCommands = map[string]bool{
+	"setname": true, "getname": true, "id": true, "info": true,
+}
+
+//check makes sure a restricted client only runs data commands, and can't push commands to a
+//command queue by any means other than the push commands, where the commands are authorized.
+func (r *redisProxy) check(s *session, cmd redcon.Command) error {
+	if !s.restricted() {
+		return nil
+	}
+
+	command := strings.ToLower(string(cmd.Args[0]))
+	if !restrictedCommands[command] {
+		return fmt.Errorf("permission denied: %s is not allowed", command)
+	}
+
+	switch command {
+	case "client":
+		if len(cmd.Args) < 2 || !restrictedClientCommands[strings.ToLower(string(cmd.Args[1]))] {
+			return fmt.Errorf("permission denied: this client subcommand is not allowed")
 		}
+	case "sort":
+		for i := 2; i < len(cmd.Args)-1; i++ {
+			if strings.ToLower(string(cmd.Args[i])) == "store" && isCommandQueue(string(cmd.Args[i+1])) {
+				return fmt.Errorf("permission denied: %s to a command queue is not allowed", command)
+			}
+		}
+	}
+
+	if i, ok := queueArgument[command]; ok && i < len(cmd.Args) && isCommandQueue(string(cmd.Args[i])) {
+		return fmt.Errorf("permission denied: %s to a command queue is not allowed", command)
 	}
-	switch result := result.(type) {
-	case []interface{}:
-		conn.WriteArray(len(result))
-		for _, elm := range result {
-			write(conn, elm)
+
+	return nil
+}
+
+//unsupported rejects the commands that break the one request one reply exchange with redis,
+//or switch the connection to a protocol the proxy doesn't speak (only RESP2 replies are parsed)
+func unsupported(c *client, cmd redcon.Command) error {
+	command := strings.ToLower(string(cmd.Args[0]))
+	switch command {
+	case "monitor", "sync", "psync", "replconf":
+		return fmt.Errorf("%s is not supported by the proxy", command)
+	case "hello":
+		if len(cmd.Args) > 1 && string(cmd.Args[1]) != "2" {
+			return fmt.Errorf("only protocol version 2 is supported by the proxy")
+		}
+	case "client":
+		if len(cmd.Args) > 1 && strings.ToLower(string(cmd.Args[1])) == "reply" {
+			return fmt.Errorf("client reply is not supported by the proxy")
+		}
+	case "unsubscribe", "punsubscribe", "sunsubscribe":
+		//outside of a subscription redis sends a reply per channel
+		if !c.relay {
+			return fmt.Errorf("%s is only allowed after a subscribe", command)
 		}
-	default:
-		write(conn, result)
 	}
+
+	return nil
 }
 
-func (r *redisProxy) handler(conn redcon.Conn, cmd redcon.Command) {
+//prepare authorizes the command, and returns the arguments to send to redis, or a reply
+//to send back to the client if the command is not proxied
+func (r *redisProxy) prepare(conn redcon.Conn, c *client, cmd redcon.Command) ([][]byte, []byte) {
+	if r.certAuth && c.session == nil {
+		r.certificate(conn, c)
+	}
+
+	if !r.addrLimit.allow(c.addr) {
+		return nil, respError("rate limit exceeded, retry later")
+	}
+
 	command := strings.ToLower(string(cmd.Args[0]))
 	if command == "auth" {
-		r.auth(conn, cmd)
-		return
+		return nil, r.auth(c, cmd)
+	}
+
+	// is authorized ?
+	if c.session == nil {
+		//session was not set, hence he either didn't call auth or not authorized
+		return nil, respError("permission denied, please call AUTH first with valid credentials")
+	}
+
+	//clients of the same identity share the identity limit, anonymous clients are only
+	//limited by address
+	if name := c.session.identity.Name; name != IdentityAnonymous && !r.identityLimit.allow(name) {
+		return nil, respError("rate limit exceeded, retry later")
 	}
 
+	if err := unsupported(c, cmd); err != nil {
+		return nil, respError(err.Error())
+	}
+
+	if err := r.check(c.session, cmd); err != nil {
+		return nil, respError(err.Error())
+	}
+
+	args := make([][]byte, len(cmd.Args))
+	copy(args, cmd.Args)
+
 	// translation for compatability with ledis
 	switch command {
 	// the next 2 cases are for compatibility with
 	// older client that worked against ledis
 	case "lkeyexists":
-		cmd.Args[0] = []byte("exists")
+		args[0] = []byte("exists")
 	case "lttl":
-		cmd.Args[0] = []byte("ttl")
+		args[0] = []byte("ttl")
+	case "rpush", "lpush", "rpushx", "lpushx":
+		if len(args) > 2 && isCommandQueue(string(args[1])) {
+			//authorize the commands and pass the caller identity along with them
+			for i := 2; i < len(args); i++ {
+				payload, err := c.session.prepare(args[i])
+				if err != nil {
+					return nil, respError(err.Error())
+				}
+				args[i] = payload
+			}
+		}
+	}
+
+	return args, nil
+}
+
+//backend gets the client connection to redis, it's opened on first use
+func (r *redisProxy) backend(c *client) (*backend, error) {
+	if c.backend != nil {
+		return c.backend, nil
+	}
+
+	b, err := dial(r.socket, r.backends)
+	if err != nil {
+		return nil, err
+	}
+
+	c.backend = b
+	return b, nil
+}
+
+func isSubscribe(command []byte) bool {
+	switch strings.ToLower(string(command)) {
+	case "subscribe", "psubscribe", "ssubscribe":
+		return true
+	}
+
+	return false
+}
+
+func isQuit(command []byte) bool {
+	return strings.ToLower(string(command)) == "quit"
+}
+
+func (r *redisProxy) handler(conn redcon.Conn, cmd redcon.Command) {
+	c := conn.Context().(*client)
+
+	if isQuit(cmd.Args[0]) {
+		conn.WriteRaw(respOK)
+		conn.Close()
+		return
+	}
+
+	args, reply := r.prepare(conn, c, cmd)
+	if reply != nil {
+		conn.WriteRaw(reply)
+		return
+	}
+
+	b, err := r.backend(c)
+	if err != nil {
+		conn.WriteError(fmt.Sprintf("ERR %s", err))
+		return
+	}
+
+	if err := b.send(args); err != nil {
+		r.reset(c)
+		conn.WriteError(fmt.Sprintf("ERR %s", err))
+		return
+	}
+
+	if isSubscribe(args[0]) {
+		//subscribe replies are pushed by redis at any time, so the client is detached
+		//from the request/reply loop and all replies are relayed as they come
+		r.relay(conn.Detach(), c)
+		return
+	}
+
+	result, err := b.receive()
+	if err != nil {
+		r.reset(c)
+		conn.WriteError(fmt.Sprintf("ERR %s", err))
+		return
+	}
+
+	conn.WriteRaw(result)
+}
+
+//reset drops a broken redis connection, a new one is opened on the next command
+func (r *redisProxy) reset(c *client) {
+	if c.backend != nil {
+		c.backend.Close()
+		c.backend = nil
+	}
+}
+
+//relay forwards the client commands to redis, and all redis replies back to the client
+func (r *redisProxy) relay(conn redcon.DetachedConn, c *client) {
+	c.relay = true
+
+	var m sync.Mutex
+	write := func(data []byte) error {
+		m.Lock()
+		defer m.Unlock()
+
+		conn.WriteRaw(data)
+		return conn.Flush()
+	}
+
+	closeConn := func() {
+		m.Lock()
+		defer m.Unlock()
+
+		conn.Close()
+	}
+
+	//replies of the commands handled before the client was detached
+	if err := write(nil); err != nil {
+		closeConn()
+		c.backend.Close()
+		r.release()
+		return
 	}
 
-	r.proxy(conn, cmd)
+	go func() {
+		defer closeConn()
+		for {
+			reply, err := c.backend.receive()
+			if err != nil {
+				return
+			}
+
+			if err := write(reply); err != nil {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer r.release()
+		defer c.backend.Close()
+		for {
+			cmd, err := conn.ReadCommand()
+			if err != nil {
+				return
+			}
+
+			if isQuit(cmd.Args[0]) {
+				write(respOK)
+				closeConn()
+				return
+			}
+
+			args, reply := r.prepare(conn, c, cmd)
+			if reply != nil {
+				if err := write(reply); err != nil {
+					return
+				}
+				continue
+			}
+
+			if err := c.backend.send(args); err != nil {
+				return
+			}
+		}
+	}()
 }
 
 func (r *redisProxy) accept(conn redcon.Conn) bool {
+	if atomic.AddInt32(&r.connections, 1) > r.maxConnections {
+		atomic.AddInt32(&r.connections, -1)
+		log.Warningf("rejecting connection from %s, max number of clients reached", conn.RemoteAddr())
+		//written to the client when the connection is closed
+		conn.WriteError("ERR max number of clients reached")
+		return false
+	}
+
+	c := &client{addr: host(conn.RemoteAddr())}
+	if !r.doAuth {
+		c.session = &session{identity: &auth.Identity{Name: IdentityAnonymous}}
+	}
+
+	conn.SetContext(c)
 	return true
 }
 
+//release frees the connection slot of a disconnected client
+func (r *redisProxy) release() {
+	atomic.AddInt32(&r.connections, -1)
+}
+
 func (r *redisProxy) closed(conn redcon.Conn, err error) {
+	c, ok := conn.Context().(*client)
+	if !ok || c.relay {
+		//relayed clients close their redis connection, and free their slot, once
+		//detached connection is closed
+		return
+	}
 
+	r.reset(c)
+	r.release()
+}
+
+//cleanup periodically drops the limits state of idle clients
+func (r *redisProxy) cleanup() {
+	for range time.Tick(limitsCleanupInterval) {
+		r.addrLimit.cleanup()
+		r.identityLimit.cleanup()
+		r.backoff.cleanup()
+	}
 }
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/redcon"
+	"github.com/zero-os/0-core/base/auth"
+)
+
+func command(args ...string) redcon.Command {
+	var cmd redcon.Command
+	for _, arg := range args {
+		cmd.Args = append(cmd.Args, []byte(arg))
+	}
+
+	return cmd
+}
+
+func TestProxyUnsupported(t *testing.T) {
+	p := &redisProxy{
+		addrLimit:     newLimiter(DefaultRate, 0),
+		identityLimit: newLimiter(DefaultRate, 0),
+	}
+
+	c := &client{
+		addr:    "127.0.0.1",
+		session: &session{identity: &auth.Identity{Name: IdentityAnonymous}},
+	}
+
+	rejected := [][]string{
+		{"MONITOR"},
+		{"sync"},
+		{"PSYNC", "?", "-1"},
+		{"hello", "3"},
+		{"HELLO", "3", "AUTH", "default", "secret"},
+		{"client", "reply", "off"},
+		{"CLIENT", "REPLY", "SKIP"},
+		{"unsubscribe", "a", "b"},
+		{"punsubscribe"},
+	}
+
+	for _, args := range rejected {
+		proxied, reply := p.prepare(nil, c, command(args...))
+		assert.Nil(t, proxied, "%v", args)
+		assert.Equal(t, byte('-'), reply[0], "%v", args)
+	}
+
+	allowed := [][]string{
+		{"hello"},
+		{"hello", "2"},
+		{"client", "setname", "test"},
+		{"llen", "core:default"},
+	}
+
+	for _, args := range allowed {
+		proxied, reply := p.prepare(nil, c, command(args...))
+		assert.Nil(t, reply, "%v", args)
+		assert.Len(t, proxied, len(args), "%v", args)
+	}
+
+	//once subscribed, replies are relayed as they come
+	c.relay = true
+	proxied, reply := p.prepare(nil, c, command("unsubscribe", "a"))
+	assert.Nil(t, reply)
+	assert.Len(t, proxied, 2)
+}
+
+func TestProxyRestricted(t *testing.T) {
+	p := &redisProxy{
+		addrLimit:     newLimiter(DefaultRate, 0),
+		identityLimit: newLimiter(DefaultRate, 0),
+	}
+
+	c := &client{
+		addr:    "127.0.0.1",
+		session: &session{identity: &auth.Identity{Name: "monitoring"}, commands: []string{"info.*"}},
+	}
+
+	rejected := [][]string{
+		//replication would replace the command queues with another dataset
+		{"REPLICAOF", "10.0.0.1", "6379"},
+		{"slaveof", "10.0.0.1", "6379"},
+		//modules and persistence write files as root
+		{"module", "load", "/tmp/module.so"},
+		{"CONFIG", "SET", "dir", "/etc"},
+		{"config", "set", "dbfilename", "crontab"},
+		{"save"},
+		{"bgsave"},
+		{"bgrewriteaof"},
+		//administration
+		{"flushall"},
+		{"flushdb"},
+		{"shutdown", "nosave"},
+		{"debug", "sleep", "10"},
+		{"migrate", "10.0.0.1", "6379", "core:default", "0", "1000"},
+		{"acl", "setuser", "default", "nopass"},
+		{"client", "kill", "127.0.0.1:4000"},
+		{"client"},
+		//scripting and indirect writes to the command queues
+		{"eval", "return 1", "0"},
+		{"script", "load", "return 1"},
+		{"sort", "list", "store", "core:default"},
+		{"rpoplpush", "list", "core:default"},
+		{"rename", "list", "core:default"},
+		{"rpush", "core:default", `{"command": "core.system"}`},
+	}
+
+	for _, args := range rejected {
+		proxied, reply := p.prepare(nil, c, command(args...))
+		assert.Nil(t, proxied, "%v", args)
+		if assert.NotNil(t, reply, "%v", args) {
+			assert.Equal(t, byte('-'), reply[0], "%v", args)
+		}
+	}
+
+	allowed := [][]string{
+		{"ping"},
+		{"client", "setname", "monitoring"},
+		{"rpush", "core:default", `{"command": "info.cpu"}`},
+		{"brpoplpush", "result:1", "result:1", "10"},
+		{"blpop", "logger:1", "10"},
+		{"get", "key"},
+		{"subscribe", "channel"},
+	}
+
+	for _, args := range allowed {
+		proxied, reply := p.prepare(nil, c, command(args...))
+		assert.Nil(t, reply, "%v", args)
+		assert.Len(t, proxied, len(args), "%v", args)
+	}
+}
+
+func TestProxyReservedConnections(t *testing.T) {
+	dir, err := ioutil.TempDir("", "redis-proxy")
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+	defer os.RemoveAll(dir)
+
+	socket := path.Join(dir, "redis.sock")
+	listener, err := net.Listen("unix", socket)
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 100)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	c := Config{RedisMaxClients: 10, Reserved: 4}
+	backends, err := c.backends()
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	p := &redisProxy{socket: socket, backends: backends}
+
+	//more clients than redis accepts, the proxy never takes the reserved connections
+	var clients []*client
+	for i := 0; i < 10; i++ {
+		client := &client{}
+		if _, err := p.backend(client); err == nil {
+			clients = append(clients, client)
+		}
+	}
+
+	assert.Len(t, clients, 6)
+	assert.Equal(t, int32(6), backends.used)
+
+	//a disconnected client frees its connection
+	p.reset(clients[0])
+	p.reset(clients[0])
+	assert.Equal(t, int32(5), backends.used)
+
+	_, err = p.backend(&client{})
+	assert.NoError(t, err)
+
+	c = Config{RedisMaxClients: 10, Reserved: 10}
+	_, err = c.backends()
+	assert.Error(t, err)
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"github.com/op/go-logging"
+)
+
+const (
+	//ScopeAdmin is granted to callers authenticated with operator issued credentials (static
+	//tokens, client certificates or custom issuer JWTs) that don't carry any scope
+	ScopeAdmin = "zero-os:admin"
+)
+
+var (
+	log = logging.MustGetLogger("auth")
+)
+
+//Identity of an authenticated caller
+type Identity struct {
+	//Name of the caller
+	Name string
+	//Scopes granted to the caller
+	Scopes []string
+}
+
+//Method validates a token, and returns the identity of the authenticated caller
+type Method func(token string) (*Identity, bool)
+
+//Chain builds a method that tries all the methods in order, the first method that accepts
+//the token authenticates the caller
+func Chain(methods ...Method) Method {
+	if len(methods) == 1 {
+		return methods[0]
+	}
+
+	return func(token string) (*Identity, bool) {
+		for _, method := range methods {
+			if identity, ok := method(token); ok {
+				return identity, true
+			}
+		}
+
+		return nil, false
+	}
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenFileMethod(t *testing.T) {
+	f, err := ioutil.TempFile("", "tokens")
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	defer os.Remove(f.Name())
+
+	f.WriteString("# static tokens\n\nsecret-1 admin\nsecret-2 monitor monitoring\n")
+	f.Close()
+
+	method, err := TokenFileMethod(f.Name())
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	identity, ok := method("secret-1")
+	assert.True(t, ok)
+	assert.Equal(t, &Identity{Name: "admin", Scopes: []string{ScopeAdmin}}, identity)
+
+	identity, ok = method("secret-2")
+	assert.True(t, ok)
+	assert.Equal(t, &Identity{Name: "monitor", Scopes: []string{"monitoring"}}, identity)
+
+	_, ok = method("secret-3")
+	assert.False(t, ok)
+}
+
+func TestChain(t *testing.T) {
+	deny := func(string) (*Identity, bool) {
+		return nil, false
+	}
+
+	allow := func(token string) (*Identity, bool) {
+		return &Identity{Name: token}, true
+	}
+
+	identity, ok := Chain(deny, allow)("test")
+	assert.True(t, ok)
+	assert.Equal(t, "test", identity.Name)
+
+	_, ok = Chain(deny)("test")
+	assert.False(t, ok)
+
+	_, ok = Chain()("test")
+	assert.False(t, ok)
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	policy := DefaultPolicy("org")
+
+	assert.Equal(t, []string{"*"}, policy.Commands(&Identity{Scopes: []string{MemberOf("org")}}))
+	assert.Equal(t, []string{"*"}, policy.Commands(&Identity{Scopes: []string{ScopeAdmin}}))
+	assert.Empty(t, policy.Commands(&Identity{Scopes: []string{MemberOf("org.sub")}}))
+}
+
+func TestCertificatesReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	defer os.RemoveAll(dir)
+
+	cert, key := path.Join(dir, "proxy.crt"), path.Join(dir, "proxy.key")
+	if !assert.NoError(t, SelfSigned(cert, key)) {
+		t.Fatal()
+	}
+
+	certs, err := NewCertificates(cert, key, "")
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	fingerprint := Fingerprint(certs.crt)
+
+	//existing self signed certificate is kept
+	assert.NoError(t, SelfSigned(cert, key))
+	assert.NoError(t, certs.Reload())
+	assert.Equal(t, fingerprint, Fingerprint(certs.crt))
+
+	os.Remove(cert)
+	assert.NoError(t, SelfSigned(cert, key))
+	assert.NoError(t, certs.Reload())
+	assert.NotEqual(t, fingerprint, Fingerprint(certs.crt))
+
+	//a broken certificate keeps the current one
+	fingerprint = Fingerprint(certs.crt)
+	ioutil.WriteFile(cert, []byte("broken"), 0644)
+	assert.Error(t, certs.Reload())
+	assert.Equal(t, fingerprint, Fingerprint(certs.crt))
+}
+
+func TestHTTP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	defer os.RemoveAll(dir)
+
+	tokens := path.Join(dir, "tokens")
+	policy := path.Join(dir, "policy.toml")
+	ioutil.WriteFile(tokens, []byte("secret-1 admin\nsecret-2 monitor monitoring\nsecret-3 other other\n"), 0600)
+	ioutil.WriteFile(policy, []byte("[role.monitoring]\nscopes = [\"monitoring\"]\ncommands = [\"aggregator.query\"]\n"), 0600)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler, err := HTTP(Options{Tokens: tokens, Policy: policy}, "aggregator.query", ok)
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	call := func(token string) int {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, call(""))
+	assert.Equal(t, http.StatusUnauthorized, call("invalid"))
+	assert.Equal(t, http.StatusOK, call("secret-2"))
+	assert.Equal(t, http.StatusForbidden, call("secret-3"))
+
+	//no authentication configured
+	handler, err = HTTP(Options{}, "aggregator.query", ok)
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+
+	assert.Equal(t, http.StatusOK, call(""))
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+//ClientCAPool loads the PEM encoded CA certificates used to verify client certificates
+func ClientCAPool(file string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no valid certificates found in '%s'", file)
+	}
+
+	return pool, nil
+}
+
+//CertificateIdentity is the identity of a caller authenticated with a verified client
+//certificate. The name is the certificate common name, the organizational units are the
+//caller scopes, and each organization grants its membership scope. Certificates without
+//any of them are granted ScopeAdmin.
+func CertificateIdentity(cert *x509.Certificate) *Identity {
+	identity := &Identity{
+		Name: cert.Subject.CommonName,
+	}
+
+	identity.Scopes = append(identity.Scopes, cert.Subject.OrganizationalUnit...)
+	for _, org := range cert.Subject.Organization {
+		identity.Scopes = append(identity.Scopes, MemberOf(org))
+	}
+
+	if len(identity.Scopes) == 0 {
+		identity.Scopes = []string{ScopeAdmin}
+	}
+
+	return identity
+}
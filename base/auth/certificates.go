@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	//CertificatesCheckInterval how often the certificate files are checked for changes
+	CertificatesCheckInterval = 10 * time.Second
+)
+
+/*
+Certificates holds a server certificate, and optionally the client CAs, loaded from files that
+can be replaced at runtime. New connections use the reloaded certificates, existing connections
+are not affected.
+*/
+type Certificates struct {
+	cert     string
+	key      string
+	clientCA string
+
+	m        sync.RWMutex
+	crt      *tls.Certificate
+	pool     *x509.CertPool
+	modified time.Time
+}
+
+//NewCertificates loads the certificate and key, and the client CAs if clientCA is set
+func NewCertificates(cert, key, clientCA string) (*Certificates, error) {
+	c := &Certificates{
+		cert:     cert,
+		key:      key,
+		clientCA: clientCA,
+	}
+
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+//Fingerprint is the SHA256 fingerprint of the certificate
+func Fingerprint(crt *tls.Certificate) string {
+	if len(crt.Certificate) == 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256(crt.Certificate[0])
+	parts := make([]string, 0, len(sum))
+	for _, b := range sum {
+		parts = append(parts, fmt.Sprintf("%02X", b))
+	}
+
+	return strings.Join(parts, ":")
+}
+
+func (c *Certificates) files() []string {
+	files := []string{c.cert, c.key}
+	if c.clientCA != "" {
+		files = append(files, c.clientCA)
+	}
+
+	return files
+}
+
+//lastModified gets the most recent modification time of the certificate files
+func (c *Certificates) lastModified() time.Time {
+	var last time.Time
+	for _, file := range c.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+
+		if info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+
+	return last
+}
+
+//Reload loads the certificate files, on failure the current certificates are kept
+func (c *Certificates) Reload() error {
+	modified := c.lastModified()
+
+	crt, err := tls.LoadX509KeyPair(c.cert, c.key)
+	if err != nil {
+		return err
+	}
+
+	var pool *x509.CertPool
+	if c.clientCA != "" {
+		if pool, err = ClientCAPool(c.clientCA); err != nil {
+			return err
+		}
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.crt = &crt
+	c.pool = pool
+	c.modified = modified
+
+	log.Infof("loaded certificate '%s' (fingerprint %s)", c.cert, Fingerprint(&crt))
+	return nil
+}
+
+//Watch reloads the certificates on SIGHUP, or when any of the files change
+func (c *Certificates) Watch() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		ticker := time.NewTicker(CertificatesCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-hup:
+			case <-ticker.C:
+				c.m.RLock()
+				modified := c.modified
+				c.m.RUnlock()
+
+				if !c.lastModified().After(modified) {
+					continue
+				}
+			}
+
+			if err := c.Reload(); err != nil {
+				log.Errorf("failed to reload certificates, keeping current ones: %s", err)
+			}
+		}
+	}()
+}
+
+//Config builds a tls configuration that always uses the most recently loaded certificates.
+//If client CAs are set, clients that present a certificate must be signed by them.
+func (c *Certificates) Config() *tls.Config {
+	return &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			c.m.RLock()
+			defer c.m.RUnlock()
+
+			return c.crt, nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			c.m.RLock()
+			defer c.m.RUnlock()
+
+			config := &tls.Config{
+				Certificates: []tls.Certificate{*c.crt},
+			}
+
+			if c.pool != nil {
+				//clients that don't present a certificate can still authenticate with AUTH
+				config.ClientAuth = tls.VerifyClientCertIfGiven
+				config.ClientCAs = c.pool
+			}
+
+			return config, nil
+		},
+	}
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//HTTPAuthenticator authenticates http requests with the configured backends, and resolves the
+//commands the callers are allowed to run from the policy
+type HTTPAuthenticator struct {
+	method  Method
+	methods int
+	policy  *Policy
+}
+
+//NewHTTPAuthenticator creates an authenticator from the options, it returns nil if no
+//authentication backend is configured.
+func NewHTTPAuthenticator(options Options) (*HTTPAuthenticator, error) {
+	if !options.Enabled() {
+		if options.Policy != "" {
+			log.Warningf("no authentication is configured, policy '%s' is ignored", options.Policy)
+		}
+		return nil, nil
+	}
+
+	methods, err := options.Methods()
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := options.LoadPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	return &HTTPAuthenticator{
+		method:  Chain(methods...),
+		methods: len(methods),
+		policy:  policy,
+	}, nil
+}
+
+//Authenticate gets the identity of the caller, and the command patterns it is allowed to run.
+//Callers authenticate with a bearer token, or with a client certificate if the server requests
+//one. The identity is nil if the caller could not be authenticated.
+func (a *HTTPAuthenticator) Authenticate(r *http.Request) (*Identity, []string) {
+	var identity *Identity
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		identity = CertificateIdentity(r.TLS.VerifiedChains[0][0])
+	} else if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); token != "" && a.methods > 0 {
+		identity, _ = a.method(token)
+	}
+
+	if identity == nil {
+		return nil, nil
+	}
+
+	return identity, a.policy.Commands(identity)
+}
+
+//HTTP wraps an http handler, so only callers that are allowed to run command by the policy
+//can call it. If no authentication backend is configured, all requests are served.
+func HTTP(options Options, command string, handler http.Handler) (http.Handler, error) {
+	authenticator, err := NewHTTPAuthenticator(options)
+	if err != nil {
+		return nil, err
+	} else if authenticator == nil {
+		return handler, nil
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, commands := authenticator.Authenticate(r)
+		if identity == nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		if !Allowed(commands, command) {
+			http.Error(w, fmt.Sprintf("permission denied: '%s' is not allowed", command), http.StatusForbidden)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	}), nil
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+const (
+	//ItsYouOnlinePublicKey is the key used to validate JWTs issued by itsyou.online
+	ItsYouOnlinePublicKey = `-----BEGIN PUBLIC KEY-----
+MHYwEAYHKoZIzj0CAQYFK4EEACIDYgAES5X8XrfKdx9gYayFITc89wad4usrk0n2
+7MjiGYvqalizeSWTHEpnd7oea9IQ8T5oJjMVH5cc0H5tFSKilFFeh//wngxIyny6
+6+Vq5t5B0V0Ehy01+2ceEon2Y0XDkIKv
+-----END PUBLIC KEY-----`
+
+	//DefaultScopeClaim is the JWT claim that holds the caller scopes
+	DefaultScopeClaim = "scope"
+)
+
+//name of the JWT owner, the subject if set, otherwise the IYO username
+func name(claims jwt.MapClaims) string {
+	for _, claim := range []string{"sub", "username", "azp"} {
+		if value, ok := claims[claim].(string); ok && value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+//scopes gets the scopes from claim, which is either a list of scopes or a space
+//separated string of scopes.
+func scopes(claims jwt.MapClaims, claim string) ([]string, bool) {
+	value, ok := claims[claim]
+	if !ok {
+		return nil, true
+	}
+
+	switch value := value.(type) {
+	case string:
+		return strings.Fields(value), true
+	case []interface{}:
+		var result []string
+		for _, s := range value {
+			if s, ok := s.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
+//MemberOf is the IYO scope of the organization members
+func MemberOf(organization string) string {
+	return fmt.Sprintf("user:memberof:%s", organization)
+}
+
+//parsePublicKey parses a PEM encoded ECDSA or RSA public key
+func parsePublicKey(key string) (interface{}, error) {
+	if pub, err := jwt.ParseECPublicKeyFromPEM([]byte(key)); err == nil {
+		return pub, nil
+	}
+
+	pub, err := jwt.ParseRSAPublicKeyFromPEM([]byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key, only ECDSA and RSA keys are supported")
+	}
+
+	return pub, nil
+}
+
+//parse validates the token signature and claims
+func parse(token string, pub interface{}) (jwt.MapClaims, bool) {
+	log.Debugf("checking token: %s", token)
+	t, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		var ok bool
+		switch pub.(type) {
+		case *ecdsa.PublicKey:
+			_, ok = t.Method.(*jwt.SigningMethodECDSA)
+		case *rsa.PublicKey:
+			_, ok = t.Method.(*jwt.SigningMethodRSA)
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+
+		return pub, nil
+	})
+
+	if err != nil {
+		log.Errorf("JWT parse error: %s", err)
+		return nil, false
+	}
+
+	if !t.Valid {
+		return nil, false
+	}
+
+	claims := t.Claims.(jwt.MapClaims)
+
+	if err := claims.Valid(); err != nil {
+		log.Errorf("JWT claim validation error: %s", err)
+		return nil, false
+	}
+
+	return claims, true
+}
+
+//JWTMethod builds an authentication method that accepts JWTs signed with key that are
+//either issued for the organization or has the membership scope of the organization or
+//one of its sub-organizations.
+func JWTMethod(organization string, key string) (Method, error) {
+	scope := MemberOf(organization)
+
+	pub, err := parsePublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(token string) (*Identity, bool) {
+		claims, ok := parse(token, pub)
+		if !ok {
+			return nil, false
+		}
+
+		identity := &Identity{
+			Name: name(claims),
+		}
+
+		if identity.Scopes, ok = scopes(claims, DefaultScopeClaim); !ok {
+			return nil, false
+		}
+
+		if claims["azp"] == organization {
+			//the token is issued for the organization itself
+			identity.Scopes = append(identity.Scopes, scope)
+			return identity, true
+		}
+
+		for _, s := range identity.Scopes {
+			if s == scope || strings.HasPrefix(s, scope+".") {
+				return identity, true
+			}
+		}
+
+		return nil, false
+	}, nil
+}
+
+//JWTClaimMethod builds an authentication method that accepts any JWT signed with key, the
+//caller scopes are read from claim (defaults to `scope`). Tokens without scopes are
+//granted ScopeAdmin.
+func JWTClaimMethod(key string, claim string) (Method, error) {
+	if claim == "" {
+		claim = DefaultScopeClaim
+	}
+
+	pub, err := parsePublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(token string) (*Identity, bool) {
+		claims, ok := parse(token, pub)
+		if !ok {
+			return nil, false
+		}
+
+		identity := &Identity{
+			Name: name(claims),
+		}
+
+		if identity.Scopes, ok = scopes(claims, claim); !ok {
+			return nil, false
+		}
+
+		if len(identity.Scopes) == 0 {
+			identity.Scopes = []string{ScopeAdmin}
+		}
+
+		return identity, true
+	}, nil
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+//Options of the authentication backends, all configured backends are tried in order. The same
+//options are used by all the services that expose a node (redis proxy, metrics endpoint).
+type Options struct {
+	//Organization IYO organization, JWTs issued by itsyou.online for the organization are accepted
+	Organization string
+	//Tokens file of static bearer tokens
+	Tokens string
+	//JWTKey file of the public key of a custom JWT issuer
+	JWTKey string
+	//JWTClaim holds the caller scopes in custom issuer JWTs
+	JWTClaim string
+	//ClientCA file of the CA certificates, client certificates signed by it are accepted
+	ClientCA string
+	//Policy file that maps scopes to allowed commands
+	Policy string
+}
+
+//Enabled checks if any authentication backend is configured
+func (o *Options) Enabled() bool {
+	return o.Organization != "" || o.Tokens != "" || o.JWTKey != "" || o.ClientCA != ""
+}
+
+//Methods builds the token authentication methods of the configured backends
+func (o *Options) Methods() ([]Method, error) {
+	var methods []Method
+	if o.Organization != "" {
+		method, err := JWTMethod(o.Organization, ItsYouOnlinePublicKey)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, method)
+	}
+
+	if o.Tokens != "" {
+		method, err := TokenFileMethod(o.Tokens)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, method)
+	}
+
+	if o.JWTKey != "" {
+		key, err := ioutil.ReadFile(o.JWTKey)
+		if err != nil {
+			return nil, err
+		}
+
+		method, err := JWTClaimMethod(string(key), o.JWTClaim)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, method)
+	}
+
+	return methods, nil
+}
+
+//LoadPolicy loads the policy file, if no policy file exists members of the organization
+//and callers with the admin scope are allowed all commands
+func (o *Options) LoadPolicy() (*Policy, error) {
+	if o.Policy != "" {
+		if _, err := os.Stat(o.Policy); err == nil {
+			log.Infof("loading policy '%s'", o.Policy)
+			return LoadPolicy(o.Policy)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return DefaultPolicy(o.Organization), nil
+}
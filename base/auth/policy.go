@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/zero-os/0-core/base/utils"
+)
+
+//Role grants access to a set of commands to the callers that has any of the role scopes
+type Role struct {
+	//Scopes of the callers that has this role
+	Scopes []string `toml:"scopes"`
+	//Organizations short hand for the membership scopes of the organizations
+	Organizations []string `toml:"organizations"`
+	//Commands name patterns of the allowed commands (ex: info.*)
+	Commands []string `toml:"commands"`
+}
+
+/*
+Policy maps callers to the commands they are allowed to run. A policy file looks like
+
+	[role.admin]
+	organizations = ["myorg"]
+	commands = ["*"]
+
+	[role.monitoring]
+	organizations = ["myorg.monitoring"]
+	commands = ["info.*", "aggregator.query"]
+*/
+type Policy struct {
+	Role map[string]Role `toml:"role"`
+}
+
+//DefaultPolicy grants all commands to the organization members, and to callers with ScopeAdmin
+func DefaultPolicy(organization string) *Policy {
+	role := Role{
+		Scopes:   []string{ScopeAdmin},
+		Commands: []string{"*"},
+	}
+
+	if organization != "" {
+		role.Organizations = []string{organization}
+	}
+
+	return &Policy{
+		Role: map[string]Role{
+			"default": role,
+		},
+	}
+}
+
+//LoadPolicy loads a policy file
+func LoadPolicy(file string) (*Policy, error) {
+	var policy Policy
+	if err := utils.LoadTomlFile(file, &policy); err != nil {
+		return nil, err
+	}
+
+	for name, role := range policy.Role {
+		for _, pattern := range role.Commands {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("role '%s' has invalid command pattern '%s': %s", name, pattern, err)
+			}
+		}
+	}
+
+	return &policy, nil
+}
+
+func (r *Role) has(identity *Identity) bool {
+	for _, scope := range identity.Scopes {
+		for _, s := range r.Scopes {
+			if s == scope {
+				return true
+			}
+		}
+
+		for _, org := range r.Organizations {
+			if MemberOf(org) == scope {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//Commands gets the command patterns the caller is allowed to run
+func (p *Policy) Commands(identity *Identity) []string {
+	var patterns []string
+	for _, role := range p.Role {
+		if role.has(identity) {
+			patterns = append(patterns, role.Commands...)
+		}
+	}
+
+	return patterns
+}
+
+//Allowed checks if a command matches any of the patterns
+func Allowed(patterns []string, command string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, command); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,4 +1,4 @@
-package main
+package auth
 
 import (
 	"bytes"
@@ -11,8 +11,11 @@ import (
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 	"math/big"
 	"net"
+	"os"
+	"path"
 	"strings"
 	"time"
 )
@@ -51,7 +54,9 @@ func pemBlockForKey(priv interface{}) (*pem.Block, error) {
 	}
 }
 
-func generateCRT() (*tls.Config, error) {
+//selfSigned generates a new self signed certificate, and returns the PEM encoded
+//certificate and key
+func selfSigned() ([]byte, []byte, error) {
 	var priv interface{}
 	var err error
 	switch ecdsaCurve {
@@ -70,7 +75,7 @@ func generateCRT() (*tls.Config, error) {
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	notBefore := time.Now()
@@ -79,7 +84,7 @@ func generateCRT() (*tls.Config, error) {
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	template := x509.Certificate{
@@ -111,7 +116,7 @@ func generateCRT() (*tls.Config, error) {
 
 	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, publicKey(priv), priv)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create certificate: %s", err)
+		return nil, nil, fmt.Errorf("failed to create certificate: %s", err)
 	}
 
 	var certOut bytes.Buffer
@@ -121,11 +126,22 @@ func generateCRT() (*tls.Config, error) {
 	var keyOut bytes.Buffer
 	privData, err := pemBlockForKey(priv)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	pem.Encode(&keyOut, privData)
 
-	crt, err := tls.X509KeyPair(certOut.Bytes(), keyOut.Bytes())
+	return certOut.Bytes(), keyOut.Bytes(), nil
+}
+
+//SelfSignedTLSConfig generates a new self signed certificate, and returns a tls
+//configuration that uses it
+func SelfSignedTLSConfig() (*tls.Config, error) {
+	cert, key, err := selfSigned()
+	if err != nil {
+		return nil, err
+	}
+
+	crt, err := tls.X509KeyPair(cert, key)
 	if err != nil {
 		return nil, err
 	}
@@ -136,3 +152,31 @@ func generateCRT() (*tls.Config, error) {
 		},
 	}, nil
 }
+
+//SelfSigned makes sure a self signed certificate exists at the given paths, a new one is
+//only generated if any of the files is missing, so the certificate fingerprint doesn't change
+//across restarts.
+func SelfSigned(cert, key string) error {
+	_, certErr := os.Stat(cert)
+	_, keyErr := os.Stat(key)
+	if certErr == nil && keyErr == nil {
+		return nil
+	}
+
+	certData, keyData, err := selfSigned()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range []string{cert, key} {
+		if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+			return err
+		}
+	}
+
+	if err := ioutil.WriteFile(key, keyData, 0600); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(cert, certData, 0644)
+}
@@ -26,9 +26,10 @@ func subscribe(ctx *pm.Context) (interface{}, error) {
 		return nil, fmt.Errorf("job '%s' does not exist", args.ID)
 	}
 
-	job.Subscribe(func(msg *stream.Message) {
+	subscription := job.Subscribe(func(msg *stream.Message) {
 		ctx.Message(msg)
 	})
+	defer job.Unsubscribe(subscription)
 
 	job.Wait()
 	return nil, nil
@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	//structuredLevel is the log level of structured json messages
+	structuredLevel = 6
+)
+
+//Fields of a structured log message
+type Fields map[string]interface{}
+
+//Logger writes structured log messages, core0 parses the fields of the messages so logs can be
+//filtered on their values (for example component=zdb or severity>=warn)
+type Logger struct {
+	out    io.Writer
+	fields Fields
+	m      sync.Mutex
+}
+
+//NewLogger creates a structured logger that writes to out, fields are added to all messages
+func NewLogger(out io.Writer, fields Fields) *Logger {
+	return &Logger{
+		out:    out,
+		fields: fields,
+	}
+}
+
+var std = NewLogger(os.Stdout, nil)
+
+//Log writes a structured message, the severity and message are added as the `severity` and
+//`message` fields
+func (l *Logger) Log(severity, message string, fields Fields) error {
+	record := Fields{}
+	for k, v := range l.fields {
+		record[k] = v
+	}
+
+	for k, v := range fields {
+		record[k] = v
+	}
+
+	record["severity"] = severity
+	record["message"] = message
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	_, err = fmt.Fprintf(l.out, "%d::%s\n", structuredLevel, data)
+	return err
+}
+
+//Debug writes a structured message with debug severity
+func (l *Logger) Debug(message string, fields Fields) error {
+	return l.Log("debug", message, fields)
+}
+
+//Info writes a structured message with info severity
+func (l *Logger) Info(message string, fields Fields) error {
+	return l.Log("info", message, fields)
+}
+
+//Warn writes a structured message with warn severity
+func (l *Logger) Warn(message string, fields Fields) error {
+	return l.Log("warn", message, fields)
+}
+
+//Error writes a structured message with error severity
+func (l *Logger) Error(message string, fields Fields) error {
+	return l.Log("error", message, fields)
+}
+
+//Log writes a structured message to stdout
+func Log(severity, message string, fields Fields) error {
+	return std.Log(severity, message, fields)
+}
@@ -37,6 +37,9 @@ type Command struct {
 	MaxRestart int `json:"max_restart,omitempty"`
 	//RecurringPeriod for recurring commands, defines how long it should wait between each run
 	RecurringPeriod int `json:"recurring_period,omitempty"`
+	//ResultTTL how long (in seconds) the job result is kept after the job exits, if not set
+	//the core default is used
+	ResultTTL int `json:"result_ttl,omitempty"`
 	//Stream if set to true, real time output of the process will get streamed over the output
 	//channel
 	Stream bool `json:"stream"`
@@ -44,6 +47,12 @@ type Command struct {
 	LogLevels []int `json:"log_levels,omitempty"`
 	//Tags custom user tags to be attached to the job
 	Tags Tags `json:"tags"`
+	//Identity of the caller that submitted the command, set by the transport the command
+	//was received on
+	Identity string `json:"identity,omitempty"`
+	//Notify if set, the job result is also sent to this target on each exit of the job. It can be
+	//either an http(s) URL to POST the result to, or a redis queue to push the result to
+	Notify string `json:"notify,omitempty"`
 
 	//For internal use only, flags that can be set from inside the internal API
 	Flags JobFlags `json:"-"`
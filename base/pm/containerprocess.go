@@ -90,6 +90,10 @@ func (p *containerProcessImpl) Command() *Command {
 	return p.cmd
 }
 
+func (p *containerProcessImpl) PID() int {
+	return p.pid
+}
+
 func (p *containerProcessImpl) Channel() Channel {
 	return p.ch
 }
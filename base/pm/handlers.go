@@ -11,6 +11,12 @@ type ResultHandler interface {
 	Result(cmd *Command, result *JobResult)
 }
 
+//ExitHandler receives the result of each run of a job that is restarted (recurring, protected or
+//restarted on failure), the final result of the job goes to the ResultHandlers
+type ExitHandler interface {
+	Exit(cmd *Command, result *JobResult)
+}
+
 //MessageHandler gets called on the receive of each single message
 //from all commands
 type MessageHandler interface {
@@ -26,3 +32,8 @@ type StatsHandler interface {
 type PreHandler interface {
 	Pre(cmd *Command)
 }
+
+//ShutdownHandler is called before the system is rebooted or powered off
+type ShutdownHandler interface {
+	Shutdown()
+}
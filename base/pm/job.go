@@ -25,7 +25,11 @@ type Job interface {
 	Process() Process
 	Wait() *JobResult
 	StartTime() int64
-	Subscribe(stream.MessageHandler)
+	//Done is closed when the job exits
+	Done() <-chan struct{}
+	//Subscribe to the job messages, the returned ID is used to unsubscribe
+	Subscribe(stream.MessageHandler) int
+	Unsubscribe(int)
 
 	start(unprivileged bool)
 }
@@ -39,11 +43,13 @@ type jobImb struct {
 	hooks       []RunnerHook
 	startTime   time.Time
 	backlog     *stream.Buffer
-	subscribers []stream.MessageHandler
+	subscribers map[int]stream.MessageHandler
+	subscriber  int
+	sm          sync.Mutex
 
 	o      sync.Once
 	result *JobResult
-	wg     sync.WaitGroup
+	done   chan struct{}
 
 	registerPID func(GetPID) (int, error)
 	waitPID     func(int) syscall.WaitStatus
@@ -75,12 +81,14 @@ func newJob(command *Command, factory ProcessFactory, hooks ...RunnerHook) Job {
 		signal:  make(chan syscall.Signal, 5), //enough buffer for 5 signals
 		hooks:   hooks,
 		backlog: stream.NewBuffer(GenericStreamBufferSize),
+		done:    make(chan struct{}),
+
+		subscribers: make(map[int]stream.MessageHandler),
 
 		registerPID: registerPID,
 		waitPID:     waitPID,
 	}
 
-	job.wg.Add(1)
 	return job
 }
 
@@ -142,7 +150,7 @@ func (r *jobImb) setUnprivileged() {
 	}
 }
 
-func (r *jobImb) Subscribe(listener stream.MessageHandler) {
+func (r *jobImb) Subscribe(listener stream.MessageHandler) int {
 	//TODO: a race condition might happen here because, while we send the backlog
 	//a new message might arrive and missed by this listener
 	for l := r.backlog.Front(); l != nil; l = l.Next() {
@@ -151,7 +159,20 @@ func (r *jobImb) Subscribe(listener stream.MessageHandler) {
 			listener(v)
 		}
 	}
-	r.subscribers = append(r.subscribers, listener)
+
+	r.sm.Lock()
+	defer r.sm.Unlock()
+
+	r.subscriber++
+	r.subscribers[r.subscriber] = listener
+	return r.subscriber
+}
+
+func (r *jobImb) Unsubscribe(id int) {
+	r.sm.Lock()
+	defer r.sm.Unlock()
+
+	delete(r.subscribers, id)
 }
 
 func (r *jobImb) callback(msg *stream.Message) {
@@ -164,7 +185,15 @@ func (r *jobImb) callback(msg *stream.Message) {
 
 	//check subscribers here.
 	msgCallback(r.command, msg)
+
+	r.sm.Lock()
+	subscribers := make([]stream.MessageHandler, 0, len(r.subscribers))
 	for _, sub := range r.subscribers {
+		subscribers = append(subscribers, sub)
+	}
+	r.sm.Unlock()
+
+	for _, sub := range subscribers {
 		sub(msg)
 	}
 }
@@ -310,7 +339,7 @@ func (r *jobImb) start(unprivileged bool) {
 			callback(r.command, result)
 
 			r.o.Do(func() {
-				r.wg.Done()
+				close(r.done)
 			})
 		}
 
@@ -327,6 +356,7 @@ loop:
 
 		if r.command.Flags.Protected {
 			//immediate restart
+			exitCallback(r.command, result)
 			log.Debugf("Re-spawning protected service '%s' in 1 second", r.command.ID)
 			<-time.After(1 * time.Second)
 			continue
@@ -355,6 +385,7 @@ loop:
 		}
 
 		if restarting {
+			exitCallback(r.command, result)
 			log.Debugf("Recurring '%s' in %s", r.command, restartIn)
 			select {
 			case <-time.After(restartIn):
@@ -387,10 +418,14 @@ func (r *jobImb) Process() Process {
 }
 
 func (r *jobImb) Wait() *JobResult {
-	r.wg.Wait()
+	<-r.done
 	return r.result
 }
 
+func (r *jobImb) Done() <-chan struct{} {
+	return r.done
+}
+
 //implement PIDTable
 //intercept pid registration to fire the correct hooks.
 func (r *jobImb) RegisterPID(g GetPID) (int, error) {
@@ -2,6 +2,7 @@ package pm
 
 import (
 	"fmt"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
@@ -120,6 +121,53 @@ func TestJobMessages(t *testing.T) {
 	}
 }
 
+func TestJobUnsubscribe(t *testing.T) {
+	New()
+
+	var action = func(ctx *Context) (interface{}, error) {
+		ctx.Log("first message", stream.LevelStdout)
+		time.Sleep(100 * time.Millisecond)
+		ctx.Log("second message", stream.LevelStdout)
+		return nil, nil
+	}
+
+	cmd := Command{}
+
+	job := newTestJob(&cmd, NewInternalProcessWithCtx(action))
+
+	var kept, removed []*stream.Message
+	job.Subscribe(func(msg *stream.Message) {
+		kept = append(kept, msg)
+	})
+
+	var id int
+	id = job.Subscribe(func(msg *stream.Message) {
+		removed = append(removed, msg)
+		job.Unsubscribe(id)
+	})
+
+	job.start(false)
+
+	select {
+	case <-job.Done():
+	default:
+		t.Fatal("job is not done")
+	}
+
+	if ok := assert.Equal(t, StateSuccess, job.Wait().State); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Len(t, kept, 3); !ok {
+		t.Error()
+	}
+
+	//the listener was removed after the first message
+	if ok := assert.Len(t, removed, 1); !ok {
+		t.Error()
+	}
+}
+
 func TestJobTimeout(t *testing.T) {
 	New()
 
@@ -204,6 +252,64 @@ func TestJobMaxRecurring(t *testing.T) {
 	}
 }
 
+type testExitHandler struct {
+	id      string
+	m       sync.Mutex
+	exits   int
+	results int
+}
+
+func (h *testExitHandler) Exit(cmd *Command, result *JobResult) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if cmd.ID == h.id {
+		h.exits++
+	}
+}
+
+func (h *testExitHandler) Result(cmd *Command, result *JobResult) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if cmd.ID == h.id {
+		h.results++
+	}
+}
+
+func TestJobRecurringExit(t *testing.T) {
+	New()
+
+	handler := &testExitHandler{id: "test-recurring-exit"}
+	AddHandle(handler)
+
+	var action = func(cmd *Command) (interface{}, error) {
+		return nil, nil
+	}
+
+	cmd := Command{
+		ID:              handler.id,
+		RecurringPeriod: 1,
+	}
+
+	job := newTestJob(&cmd, NewInternalProcess(action))
+
+	go func() {
+		time.Sleep(2500 * time.Millisecond)
+		job.Signal(syscall.SIGKILL)
+	}()
+
+	job.start(false)
+
+	result := job.Wait()
+	assert.Equal(t, StateKilled, result.State)
+
+	handler.m.Lock()
+	defer handler.m.Unlock()
+
+	//each run is reported to the exit handlers, only the final result to the result handlers
+	assert.InDelta(t, 3, handler.exits, 1)
+	assert.Equal(t, 1, handler.results)
+}
+
 func TestJobHooks(t *testing.T) {
 	t.Skip()
 	New()
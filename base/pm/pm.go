@@ -21,6 +21,25 @@ import (
 const (
 	AggreagteAverage    = "A"
 	AggreagteDifference = "D"
+	AggreagteGauge      = "G"
+	AggreagteCounter    = "C"
+	AggreagteHistogram  = "H"
+)
+
+var (
+	//statsTypes maps the statsd message types to the aggregation operations, the standard statsd
+	//types are accepted as well
+	statsTypes = map[string]string{
+		AggreagteAverage:    AggreagteAverage,
+		AggreagteDifference: AggreagteDifference,
+		AggreagteGauge:      AggreagteGauge,
+		AggreagteCounter:    AggreagteCounter,
+		AggreagteHistogram:  AggreagteHistogram,
+		"g":                 AggreagteGauge,
+		"c":                 AggreagteCounter,
+		"h":                 AggreagteHistogram,
+		"ms":                AggreagteHistogram,
+	}
 )
 
 var (
@@ -408,6 +427,15 @@ func Kill(cmdID string) error {
 	return nil
 }
 
+//Shutdown notifies the handlers that the system is going down
+func Shutdown() {
+	for _, handler := range handlers {
+		if handler, ok := handler.(ShutdownHandler); ok {
+			handler.Shutdown()
+		}
+	}
+}
+
 func Aggregate(op, key string, value float64, id string, tags ...Tag) {
 	for _, handler := range handlers {
 		if handler, ok := handler.(StatsHandler); ok {
@@ -420,9 +448,14 @@ func handleStatsMessage(cmd *Command, msg *stream.Message) {
 	parts := strings.Split(msg.Message, "|")
 	if len(parts) < 2 {
 		log.Errorf("Invalid statsd string, expecting data|type[|options], got '%s'", msg.Message)
+		return
 	}
 
-	optype := parts[1]
+	optype, ok := statsTypes[strings.TrimSpace(parts[1])]
+	if !ok {
+		log.Errorf("Invalid statsd type '%s', expecting one of A, D, G, C, H", parts[1])
+		return
+	}
 
 	var tagsStr string
 	if len(parts) == 3 {
@@ -432,6 +465,7 @@ func handleStatsMessage(cmd *Command, msg *stream.Message) {
 	data := strings.Split(parts[0], ":")
 	if len(data) != 2 {
 		log.Errorf("Invalid statsd data, expecting key:value, got '%s'", parts[0])
+		return
 	}
 
 	key := strings.Trim(data[0], " ")
@@ -488,6 +522,15 @@ func msgCallback(cmd *Command, msg *stream.Message) {
 	}
 }
 
+func exitCallback(cmd *Command, result *JobResult) {
+	result.Tags = cmd.Tags
+	for _, handler := range handlers {
+		if handler, ok := handler.(ExitHandler); ok {
+			handler.Exit(cmd, result)
+		}
+	}
+}
+
 func callback(cmd *Command, result *JobResult) {
 	result.Tags = cmd.Tags
 	for _, handler := range handlers {
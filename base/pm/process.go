@@ -50,5 +50,11 @@ type Stater interface {
 	Stats() *ProcessStats
 }
 
+//PIDer a process that exposes its PID, the PID is 0 until the process is started
+type PIDer interface {
+	Process
+	PID() int
+}
+
 //ProcessFactory interface
 type ProcessFactory func(PIDTable, *Command) Process
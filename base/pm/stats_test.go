@@ -0,0 +1,34 @@
+package pm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-core/base/pm/stream"
+)
+
+type testStatsHandler struct {
+	ops []string
+}
+
+func (h *testStatsHandler) Stats(op string, key string, value float64, id string, tags ...Tag) {
+	h.ops = append(h.ops, op)
+}
+
+func TestHandleStatsMessage(t *testing.T) {
+	defer func(h []Handler) {
+		handlers = h
+	}(handlers)
+
+	var handler testStatsHandler
+	handlers = []Handler{&handler}
+
+	for _, line := range []string{
+		"key:1|A", "key:1|D", "key:1|G", "key:1|C", "key:1|H|id=1",
+		"key:1|g", "key:1|c", "key:1|ms", "key:1|X", "key|A", "key:1",
+	} {
+		handleStatsMessage(nil, &stream.Message{Message: line})
+	}
+
+	assert.Equal(t, []string{"A", "D", "G", "C", "H", "G", "C", "H"}, handler.ops)
+}
@@ -48,6 +48,10 @@ func (p *systemProcessImpl) Command() *Command {
 	return p.cmd
 }
 
+func (p *systemProcessImpl) PID() int {
+	return p.pid
+}
+
 //GetStats gets stats of an external p
 func (p *systemProcessImpl) Stats() *ProcessStats {
 	stats := ProcessStats{}
@@ -1,6 +1,9 @@
 package settings
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/op/go-logging"
 	"github.com/zero-os/0-core/base/utils"
 )
@@ -12,6 +15,9 @@ var (
 const (
 	//ConfigSuffix config file ext
 	ConfigSuffix = ".toml"
+	//SinkQueuePrefix all the sink command queues must start with this prefix, the redis proxy
+	//authorizes any push to a queue with this prefix as a command
+	SinkQueuePrefix = "core:"
 )
 
 //Logger settings
@@ -44,6 +50,13 @@ type Security struct {
 	ClientCertificateKey string
 }
 
+//SinkQueue defines a named commands queue, queues with higher priority are
+//always served first
+type SinkQueue struct {
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
+}
+
 type Globals map[string]string
 
 func (g Globals) Get(key string, def ...string) string {
@@ -55,6 +68,23 @@ func (g Globals) Get(key string, def ...string) string {
 	return v
 }
 
+//StatsPeriod is an aggregation period in seconds, and the number of samples kept in its history
+type StatsPeriod struct {
+	Duration int64 `json:"duration"`
+	History  int   `json:"history"`
+}
+
+//AlertRule is an alerting rule on the aggregated stats, see stats.Rule
+type AlertRule struct {
+	Key        string            `json:"key"`
+	Tags       map[string]string `json:"tags"`
+	Period     int64             `json:"period"`
+	Field      string            `json:"field"`
+	Comparison string            `json:"comparison"`
+	Threshold  float64           `json:"threshold"`
+	Duration   int64             `json:"duration"`
+}
+
 //Settings main agent settings
 type AppSettings struct {
 	Main struct {
@@ -69,17 +99,88 @@ type AppSettings struct {
 	Logging   struct {
 		File  Logger `json:"file"`
 		Ledis struct {
-			Logger `json:"ledis"`
-			Size   int64 `json:"size"`
+			Levels []uint16 `json:"levels"`
+			Size   int64    `json:"size"`
+		}
+		Store struct {
+			Levels  []uint16 `json:"levels"`
+			Enabled bool     `json:"enabled"`
+			Dir     string   `json:"dir"`
+			Size    int64    `json:"size"`
+		}
+		Syslog struct {
+			Levels    []uint16 `json:"levels"`
+			Enabled   bool     `json:"enabled"`
+			Address   string   `json:"address"`
+			Facility  int      `json:"facility"`
+			CA        string   `json:"ca"`
+			Spool     string   `json:"spool"`
+			SpoolSize int64    `json:"spool_size"`
 		}
 	} `json:"logger"`
 
 	Containers struct {
 		MaxCount int `json:"max_count"`
 	} `json:"containers"`
+	Sink struct {
+		Workers     int         `json:"workers"`
+		Queue       []SinkQueue `json:"queue"`
+		ResultTTL   int         `json:"result_ttl"`
+		ResultStore string      `json:"result_store"`
+	} `json:"sink"`
+	API struct {
+		Enabled bool   `json:"enabled"`
+		Listen  string `json:"listen"`
+		Cert    string `json:"cert"`
+		Key     string `json:"key"`
+		//authentication backends, same as the redis proxy
+		Tokens   string `json:"tokens"`
+		JWTKey   string `json:"jwt_key"`
+		JWTClaim string `json:"jwt_claim"`
+		ClientCA string `json:"client_ca"`
+		Policy   string `json:"policy"`
+	} `json:"api"`
+	Audit struct {
+		Enabled bool   `json:"enabled"`
+		File    string `json:"file"`
+	} `json:"audit"`
 	Stats struct {
 		Enabled bool `json:"enabled"`
+		//Expire seconds a metric is kept after it was last reported
+		Expire int           `json:"expire"`
+		Period []StatsPeriod `json:"period"`
+		//Snapshot of the stats history on the cache disk, so it survives reboots
+		Snapshot struct {
+			Enabled bool   `json:"enabled"`
+			File    string `json:"file"`
+			//Interval seconds between 2 snapshots
+			Interval int `json:"interval"`
+		} `json:"snapshot"`
+		//Export pushes all stats to a remote collector
+		Export struct {
+			Enabled bool   `json:"enabled"`
+			Target  string `json:"target"`
+			//Interval seconds between 2 flushes
+			Interval   int               `json:"interval"`
+			Buffer     string            `json:"buffer"`
+			BufferSize int64             `json:"buffer_size"`
+			Tags       map[string]string `json:"tags"`
+		} `json:"export"`
+		Prometheus struct {
+			Enabled bool   `json:"enabled"`
+			Listen  string `json:"listen"`
+			//Cert and Key of the server certificate
+			Cert string `json:"cert"`
+			Key  string `json:"key"`
+			//authentication backends, same as the redis proxy
+			Tokens   string `json:"tokens"`
+			JWTKey   string `json:"jwt_key"`
+			JWTClaim string `json:"jwt_claim"`
+			ClientCA string `json:"client_ca"`
+			Policy   string `json:"policy"`
+		} `json:"prometheus"`
 	} `json:"stats"`
+	Alert map[string]AlertRule `json:"alert"`
 }
 
 var Settings AppSettings
@@ -89,7 +190,14 @@ func (s *AppSettings) Validate() []error {
 		s.Main.LogLevel = "info"
 	}
 
-	return nil
+	var errors []error
+	for _, queue := range s.Sink.Queue {
+		if !strings.HasPrefix(queue.Name, SinkQueuePrefix) {
+			errors = append(errors, fmt.Errorf("sink queue '%s' must start with '%s'", queue.Name, SinkQueuePrefix))
+		}
+	}
+
+	return errors
 }
 
 //GetSettings loads main settings from a filename
@@ -0,0 +1,70 @@
+package settings
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSinkQueues(t *testing.T) {
+	var s AppSettings
+	s.Sink.Queue = []SinkQueue{
+		{Name: "core:default"},
+		{Name: "jobs"},
+		{Name: ""},
+	}
+
+	errors := s.Validate()
+	if ok := assert.Len(t, errors, 2); !ok {
+		t.Fatal()
+	}
+
+	assert.Contains(t, errors[0].Error(), "'jobs'")
+	assert.Equal(t, "info", s.Main.LogLevel)
+}
+
+//the logging example of docs/config/main.md
+func TestLoadLoggingLevels(t *testing.T) {
+	f, err := ioutil.TempFile("", "settings")
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`
+[logging.file]
+levels = [2, 4, 7, 8, 9]
+
+[logging.ledis]
+levels = [1, 2, 4, 7, 8, 9]
+size = 1000
+
+[logging.store]
+enabled = true
+dir = "/var/log/core"
+size = 100
+levels = [1, 2]
+
+[logging.syslog]
+enabled = true
+address = "tls://syslog.example.com:6514"
+levels = [2, 4, 7, 8, 9]
+`)
+	f.Close()
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.NoError(t, LoadSettings(f.Name())); !ok {
+		t.Fatal()
+	}
+
+	assert.Equal(t, []uint16{2, 4, 7, 8, 9}, Settings.Logging.File.Levels)
+	assert.Equal(t, []uint16{1, 2, 4, 7, 8, 9}, Settings.Logging.Ledis.Levels)
+	assert.True(t, Settings.Logging.Store.Enabled)
+	assert.Equal(t, []uint16{1, 2}, Settings.Logging.Store.Levels)
+	assert.Equal(t, "tls://syslog.example.com:6514", Settings.Logging.Syslog.Address)
+	assert.Equal(t, []uint16{2, 4, 7, 8, 9}, Settings.Logging.Syslog.Levels)
+}
@@ -17,8 +17,13 @@ type Command struct {
 	MaxTime         int    `json:"max_time,omitempty"`
 	MaxRestart      int    `json:"max_restart,omitempty"`
 	RecurringPeriod int    `json:"recurring_period,omitempty"`
+	ResultTTL       int    `json:"result_ttl,omitempty"`
 	LogLevels       []int  `json:"log_levels,omitempty"`
 	Tags            Tags   `json:"tags"`
+	Notify          string `json:"notify,omitempty"`
+
+	//target is the core commands queue the command is pushed to
+	target string
 }
 
 type Option interface {
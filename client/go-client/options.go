@@ -48,6 +48,19 @@ func RecurringPeriod(period int) Option {
 	return recurringPeriodOpt{period}
 }
 
+type resultTTLOpt struct {
+	ttl int
+}
+
+func (o resultTTLOpt) apply(cmd *Command) {
+	cmd.ResultTTL = o.ttl
+}
+
+//ResultTTL sets how long (in seconds) the job result is kept after the job exits
+func ResultTTL(ttl int) Option {
+	return resultTTLOpt{ttl}
+}
+
 type idOpt struct {
 	id string
 }
@@ -59,3 +72,31 @@ func (o idOpt) apply(cmd *Command) {
 func ID(id string) Option {
 	return idOpt{id}
 }
+
+type targetOpt struct {
+	target string
+}
+
+func (o targetOpt) apply(cmd *Command) {
+	cmd.target = o.target
+}
+
+//Target sets the core commands queue (ex: UrgentCommandsQueue) the command is pushed to,
+//defaults to CommandsQueue. Not to be confused with Queue which serializes job execution.
+func Target(queue string) Option {
+	return targetOpt{queue}
+}
+
+type notifyOpt struct {
+	target string
+}
+
+func (o notifyOpt) apply(cmd *Command) {
+	cmd.Notify = o.target
+}
+
+//Notify sends the job result to target once the job exits. Target is either an http(s) URL
+//to POST the result to, or a redis queue to push the result to
+func Notify(target string) Option {
+	return notifyOpt{target}
+}
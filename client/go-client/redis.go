@@ -12,7 +12,9 @@ import (
 )
 
 const (
-	CommandsQueue = "core:default"
+	CommandsQueue       = "core:default"
+	UrgentCommandsQueue = "core:urgent"
+	BulkCommandsQueue   = "core:bulk"
 
 	ResultNoTimeout      = 0
 	ResultDefaultTimeout = 10
@@ -98,7 +100,12 @@ func (c *redisClient) Raw(name string, args A, opts ...Option) (JobId, error) {
 		return JobId(""), err
 	}
 
-	if _, err := db.Do("RPUSH", CommandsQueue, string(data)); err != nil {
+	target := cmd.target
+	if target == "" {
+		target = CommandsQueue
+	}
+
+	if _, err := db.Do("RPUSH", target, string(data)); err != nil {
 		return JobId(""), err
 	}
 
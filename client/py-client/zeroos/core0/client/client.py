@@ -347,6 +347,13 @@ class InfoManager:
         """
         return self._client.json('info.version', {})
 
+    def sensors(self):
+        """
+        Hardware sensors (temperatures, fans and power) readings
+        :return:
+        """
+        return self._client.json('info.sensors', {})
+
     def dmi(self, *types):
         """
         Get dmi output
@@ -405,6 +412,13 @@ class ProcessManager:
         'signal': int,
     })
 
+    _top_chk = typchk.Checker({
+        'sort': str,
+        'limit': int,
+        'job': str,
+        'container': int,
+    })
+
     def __init__(self, client):
         self._client = client
 
@@ -433,6 +447,25 @@ class ProcessManager:
         self._kill_chk.check(args)
         return self._client.json('process.kill', args)
 
+    def top(self, sort='cpu', limit=10, job='', container=0):
+        """
+        Get the processes of the jobs with the highest resources usage over the last sampling interval
+        of the `processes` monitor (only available on the node)
+
+        :param sort: resource to sort on (cpu, rss, read, write, io or fds)
+        :param limit: max number of processes to return
+        :param job: optional job ID to only list the processes of this job
+        :param container: optional container ID to only list the processes of this container
+        """
+        args = {
+            'sort': sort,
+            'limit': limit,
+            'job': job,
+            'container': container,
+        }
+        self._top_chk.check(args)
+        return self._client.json('process.top', args)
+
 
 class FilesystemManager:
 
@@ -2588,8 +2621,34 @@ class Logger:
     })
 
     _subscribe_chk = typchk.Checker({
-        'queue': str,
+        'queue': typchk.Or(str, typchk.IsNone()),
         'levels': [int],
+        'core': typchk.Or(int, typchk.IsNone()),
+        'prefix': typchk.Or(str, typchk.IsNone()),
+        'tags': [str],
+        'fields': [str],
+        'expire': typchk.Or(int, typchk.IsNone()),
+    })
+
+    _query_chk = typchk.Checker({
+        'command': typchk.Or(str, typchk.IsNone()),
+        'core': typchk.Or(int, typchk.IsNone()),
+        'levels': [int],
+        'since': typchk.Or(int, typchk.IsNone()),
+        'until': typchk.Or(int, typchk.IsNone()),
+        'limit': typchk.Or(int, typchk.IsNone()),
+        'cursor': typchk.Or(str, typchk.IsNone()),
+        'fields': [str],
+    })
+
+    _syslog_chk = typchk.Checker({
+        'enabled': bool,
+        'address': typchk.Or(str, typchk.IsNone()),
+        'levels': [int],
+        'facility': typchk.Or(int, typchk.IsNone()),
+        'ca': typchk.Or(str, typchk.IsNone()),
+        'spool': typchk.Or(str, typchk.IsNone()),
+        'spool_size': typchk.Or(int, typchk.IsNone()),
     })
 
     def __init__(self, client):
@@ -2615,7 +2674,7 @@ class Logger:
         """
         return self._client.json('logger.reopen', {})
 
-    def subscribe(self, queue=None, *levels):
+    def subscribe(self, queue=None, *levels, core=None, prefix=None, tags=[], fields=[], expire=None):
         """
         Subscribe to the aggregated log stream. On subscribe a ledis queue will be fed with all running processes
         logs. Always use the returned queue name from this method, even if u specified the queue name to use
@@ -2625,11 +2684,21 @@ class Logger:
 
         :param queue: Your unique queue name, otherwise, a one will get generated for your
         :param levels:
+        :param core: only logs of this container id (0 for core0 itself)
+        :param prefix: only logs of jobs with an id that starts with prefix
+        :param tags: only logs of jobs that have all these tags
+        :param fields: filters on the fields of structured logs (ex: ['component=zdb', 'severity>=warn'])
+        :param expire: seconds after which the subscription is dropped if the queue is not consumed (default 3600)
         :return: queue name to pull from
         """
         args = {
             'queue': queue,
             'levels': list(levels),
+            'core': core,
+            'prefix': prefix,
+            'tags': tags,
+            'fields': fields,
+            'expire': expire,
         }
 
         self._subscribe_chk.check(args)
@@ -2646,6 +2715,67 @@ class Logger:
         """
         return self._client.json('logger.unsubscribe', {'queue': queue})
 
+    def query(self, command=None, core=None, levels=[], since=None, until=None, limit=None, cursor=None, fields=[]):
+        """
+        Query the logs kept on disk by the log store, oldest first
+
+        :param command: job id
+        :param core: container id (0 for core0 itself)
+        :param levels: list of log levels
+        :param since: unix timestamp
+        :param until: unix timestamp
+        :param limit: max number of records to return (default 100)
+        :param cursor: cursor returned by a previous query, to get the next page
+        :param fields: filters on the fields of structured logs (ex: ['component=zdb', 'severity>=warn'])
+        :return: dict with the matching 'records', the 'cursor' of the next page, and 'more' if there are more records
+        """
+        args = {
+            'command': command,
+            'core': core,
+            'levels': levels,
+            'since': since,
+            'until': until,
+            'limit': limit,
+            'cursor': cursor,
+            'fields': fields,
+        }
+
+        self._query_chk.check(args)
+
+        return self._client.json('logger.query', args)
+
+    def syslog_set(self, enabled=True, address=None, levels=[], facility=None, ca=None, spool=None, spool_size=None):
+        """
+        Configure forwarding of the logs to a syslog server, the configuration replaces the current one
+
+        :param enabled: enable or disable forwarding
+        :param address: syslog server address as udp://host:port, tcp://host:port or tls://host:port
+        :param levels: list of log levels to forward (all levels if empty)
+        :param facility: syslog facility code (default 1, user-level messages)
+        :param ca: CA file on the node to verify the server certificate (tls only)
+        :param spool: file where logs are kept while the server is not reachable
+        :param spool_size: max size of the spool in MiB (default 10)
+        """
+        args = {
+            'enabled': enabled,
+            'address': address,
+            'levels': levels,
+            'facility': facility,
+            'ca': ca,
+            'spool': spool,
+            'spool_size': spool_size,
+        }
+
+        self._syslog_chk.check(args)
+
+        return self._client.json('logger.syslog.set', args)
+
+    def syslog_get(self):
+        """
+        Get the current syslog forwarding configuration
+        """
+        return self._client.json('logger.syslog.get', {})
+
 
 
 class Nft:
@@ -2729,12 +2859,15 @@ class AggregatorManager:
     _query_chk = typchk.Checker({
         'key': typchk.Or(str, typchk.IsNone()),
         'tags': typchk.Map(str, str),
+        'period': typchk.Or(int, typchk.IsNone()),
+        'since': typchk.Or(int, typchk.IsNone()),
+        'until': typchk.Or(int, typchk.IsNone()),
     })
 
     def __init__(self, client):
         self._client = client
 
-    def query(self, key=None, **tags):
+    def query(self, key=None, period=None, since=None, until=None, **tags):
         """
         Query zero-os aggregator for current state object of monitored metrics.
 
@@ -2747,6 +2880,9 @@ class AggregatorManager:
             self.query(key=key, id=value)
 
         :param key: metric key (ex: machine.memory.ram.available)
+        :param period: only return the samples of this period (in seconds)
+        :param since: only return the history samples that started after this unix timestamp (requires period)
+        :param until: only return the history samples that started before this unix timestamp (requires period)
         :param tags: optional tags filter
         :return: dict of {
             'key[/id]': state object
@@ -2755,11 +2891,86 @@ class AggregatorManager:
         args = {
             'key': key,
             'tags': tags,
+            'period': period,
+            'since': since,
+            'until': until,
         }
         self._query_chk.check(args)
 
         return self._client.json('aggregator.query', args)
 
+    def snapshot(self):
+        """
+        Save the stats history to the cache disk now (requires stats snapshots to be enabled), so it's
+        restored after the next reboot
+        """
+        return self._client.json('aggregator.snapshot', {})
+
+
+class AlertManager:
+    _set_chk = typchk.Checker({
+        'name': str,
+        'key': str,
+        'tags': typchk.Map(str, str),
+        'period': typchk.Or(int, typchk.IsNone()),
+        'field': typchk.Or(str, typchk.IsNone()),
+        'comparison': typchk.Enum('>', '>=', '<', '<=', '==', '!='),
+        'threshold': typchk.Or(int, float),
+        'duration': typchk.Or(int, typchk.IsNone()),
+    })
+
+    def __init__(self, client):
+        self._client = client
+
+    def set(self, name, key, comparison, threshold, period=None, field=None, duration=None, **tags):
+        """
+        Add an alerting rule on the aggregated stats, or replace the rule with the same name
+
+        :example:
+            self.set('cpu_high', 'machine.CPU.percent', '>', 90, period=300, duration=900, type='phys')
+
+        :param name: rule name
+        :param key: metric key (ex: machine.CPU.percent)
+        :param comparison: one of >, >=, <, <=, ==, !=
+        :param threshold: value the field of the samples is compared to
+        :param period: period of the evaluated samples (default to the shortest period)
+        :param field: field of the samples, avg (default), max, last, total, count or p50, p90, p99 for histograms
+        :param duration: seconds the comparison must hold before the alert fires
+        :param tags: tags the metric must have (including the id)
+        """
+        args = {
+            'name': name,
+            'key': key,
+            'tags': tags,
+            'period': period,
+            'field': field,
+            'comparison': comparison,
+            'threshold': threshold,
+            'duration': duration,
+        }
+        self._set_chk.check(args)
+
+        return self._client.json('alert.set', args)
+
+    def delete(self, name):
+        """
+        Delete an alerting rule
+
+        :param name: rule name
+        """
+        return self._client.json('alert.delete', {'name': name})
+
+    def list(self):
+        """
+        List the alerting rules and their pending and firing alerts
+
+        :return: dict of {
+            'name': {'rule': rule, 'alerts': [alert]}
+        }
+        """
+        return self._client.json('alert.list', {})
+
+
 class RTInfoManager:
     _rtinfo_start_params_chk = typchk.Checker({
         'host': str,
@@ -3029,6 +3240,7 @@ class Client(BaseClient):
         self._nft = Nft(self)
         self._config = Config(self)
         self._aggregator = AggregatorManager(self)
+        self._alert = AlertManager(self)
         self._rtinfo = RTInfoManager(self)
         self._cgroup = CGroupManager(self)
 
@@ -3122,6 +3334,14 @@ class Client(BaseClient):
         """
         return self._aggregator
 
+    @property
+    def alert(self):
+        """
+        Alert manager
+        :return:
+        """
+        return self._alert
+
     @property
     def rtinfo(self):
         """